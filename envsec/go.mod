@@ -4,6 +4,7 @@ go 1.21
 
 require (
 	connectrpc.com/connect v1.16.0
+	filippo.io/age v1.1.1
 	github.com/AlecAivazis/survey/v2 v2.3.7
 	github.com/MakeNowJust/heredoc v1.0.0
 	github.com/aws/aws-sdk-go-v2 v1.26.1
@@ -23,7 +24,10 @@ require (
 	github.com/spf13/cobra v1.8.0
 	go.jetify.com/typeid v1.2.1-0.20240604165525-f81dd7018dac
 	go.jetpack.io/pkg v0.0.0-20240625214343-5582d7d229e2
+	golang.org/x/sys v0.19.0
+	golang.org/x/term v0.19.0
 	golang.org/x/text v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -65,8 +69,5 @@ require (
 	golang.org/x/crypto v0.22.0 // indirect
 	golang.org/x/exp v0.0.0-20240409090435-93d18d7e34b8 // indirect
 	golang.org/x/oauth2 v0.19.0 // indirect
-	golang.org/x/sys v0.19.0 // indirect
-	golang.org/x/term v0.19.0 // indirect
 	google.golang.org/protobuf v1.33.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,64 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"github.com/MakeNowJust/heredoc"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetpack.io/envsec/pkg/envsec"
+)
+
+type applyCmdFlags struct {
+	configFlags
+	name      string
+	namespace string
+	dir       string
+	raw       bool
+}
+
+func ApplyCmd() *cobra.Command {
+	flags := &applyCmdFlags{}
+	command := &cobra.Command{
+		Use:   "apply --name <name> --dir <directory>",
+		Short: "Write environment variables as a Kubernetes Secret and ConfigMap for kustomize",
+		Long: heredoc.Doc(`
+			Write environment variables to a kustomize directory, without kubectl. Secrets
+			go in a Secret named --name, written to secret.yaml, and variables set with
+			--sensitivity config go in a ConfigMap named <name>-config, written to
+			configmap.yaml. A kustomization.yaml listing both is added if the directory
+			doesn't have one.
+
+			To apply the variables to a cluster directly, pipe
+			'envsec download --format k8s-secret -' to 'kubectl apply -f -'.
+		`),
+		Example: heredoc.Doc(`
+			envsec apply --name app-secrets --namespace prod --dir k8s/overlays/prod
+		`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			return cmdCfg.envsec.ApplyKubernetes(cmd.Context(), envsec.ApplyOptions{
+				KubernetesOptions: envsec.KubernetesOptions{
+					Name:      flags.name,
+					Namespace: flags.namespace,
+				},
+				Dir: flags.dir,
+				Raw: flags.raw,
+			})
+		},
+	}
+
+	command.Flags().StringVar(&flags.name, "name", "", "name of the Secret")
+	command.Flags().StringVar(
+		&flags.namespace, "namespace", "", "namespace of the objects. Defaults to none, for kustomize to set")
+	command.Flags().StringVar(&flags.dir, "dir", "", "directory to write the manifests to")
+	command.Flags().BoolVar(
+		&flags.raw, "raw", false, "apply values as stored, even if the project expands ${VAR} references")
+	flags.configFlags.register(command)
+	return command
+}
@@ -0,0 +1,135 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetpack.io/envsec/internal/tux"
+	"go.jetpack.io/envsec/pkg/envsec"
+)
+
+type auditCmdFlags struct {
+	file    string
+	since   time.Duration
+	action  string
+	user    string
+	envName string
+	name    string
+	format  string
+}
+
+func AuditCmd() *cobra.Command {
+	flags := &auditCmdFlags{}
+	command := &cobra.Command{
+		Use:   "audit",
+		Short: "Show who read or changed environment variables",
+		Long:  "Show the local audit log, which records who read or changed which environment variables, and when. Values are never recorded.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			path := flags.file
+			if path == "" {
+				var err error
+				if path, err = envsec.DefaultAuditLogPath(); err != nil {
+					return errors.WithStack(err)
+				}
+			}
+
+			filter := envsec.AuditFilter{
+				Action:      envsec.AuditAction(flags.action),
+				User:        flags.user,
+				Environment: flags.envName,
+				Name:        flags.name,
+			}
+			if flags.since > 0 {
+				filter.Since = time.Now().Add(-flags.since)
+			}
+			events, err := envsec.ReadAuditLog(path, filter)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			if flags.format == "table" {
+				if err := tux.WriteHeader(cmd.OutOrStdout(), "Audit log: %s\n", path); err != nil {
+					return errors.WithStack(err)
+				}
+			}
+			return envsec.PrintAuditEvents(cmd.OutOrStdout(), events, flags.format)
+		},
+	}
+
+	command.Flags().StringVar(
+		&flags.file, "file", "", "audit log to read, instead of the one in your user config directory")
+	command.Flags().DurationVar(
+		&flags.since, "since", 0, "only show events newer than this, such as 24h")
+	command.Flags().StringVar(
+		&flags.action, "action", "", "only show events of this action, one of: set, delete, list, download, exec, apply, reference, sync")
+	command.Flags().StringVar(
+		&flags.user, "user", "", "only show events of this user ID or email")
+	command.Flags().StringVar(
+		&flags.envName, "environment", "", "only show events of this environment")
+	command.Flags().StringVar(
+		&flags.name, "name", "", "only show events involving this variable")
+	command.Flags().StringVarP(
+		&flags.format, "format", "f", "table", "format to use for displaying events, one of: table, json")
+
+	return command
+}
+
+// auditSink returns the sink that receives the audit events of e: the local
+// audit log, plus the sinks in ENVSEC_AUDIT_SINKS (a comma-separated list of
+// URLs) or in the project's config.
+//
+// Webhooks are only taken from ENVSEC_AUDIT_SINKS. The project's config comes
+// with the repository, so a webhook there would send the user's identity and
+// the names of the variables they use to a URL of the repository's choosing,
+// just for running envsec in a clone of it.
+func auditSink(e *envsec.Envsec) (envsec.AuditSink, error) {
+	path, err := envsec.DefaultAuditLogPath()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sinks := envsec.MultiAuditSink{&envsec.FileAuditSink{Path: path}}
+
+	if env := os.Getenv("ENVSEC_AUDIT_SINKS"); env != "" {
+		for _, url := range strings.Split(env, ",") {
+			sink, err := envsec.NewAuditSink(strings.TrimSpace(url))
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		}
+	} else if config, err := e.ProjectConfig(); err == nil {
+		for _, url := range config.AuditSinks {
+			sink, err := envsec.NewAuditSink(strings.TrimSpace(url))
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := sink.(*envsec.FileAuditSink); !ok {
+				fmt.Fprintf(e.Stderr,
+					"Warning: ignoring audit sink %s in the project's config. "+
+						"Webhooks can only be set in ENVSEC_AUDIT_SINKS\n",
+					url,
+				)
+				continue
+			}
+			sinks = append(sinks, sink)
+		}
+	}
+	return sinks, nil
+}
+
+// envVarNames returns the names of vars.
+func envVarNames(vars []envsec.EnvVar) []string {
+	names := make([]string, 0, len(vars))
+	for _, v := range vars {
+		names = append(names, v.Name)
+	}
+	return names
+}
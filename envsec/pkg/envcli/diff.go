@@ -0,0 +1,77 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetpack.io/envsec/pkg/envsec"
+)
+
+type diffCmdFlags struct {
+	configFlags
+	from       string
+	to         string
+	ShowValues bool
+	Format     string
+}
+
+func DiffCmd() *cobra.Command {
+	flags := &diffCmdFlags{}
+	command := &cobra.Command{
+		Use:   "diff --from <environment> --to <environment>",
+		Short: "Compare the environment variables of two environments",
+		Long:  "Compare the environment variables of two environments, listing the names that are added, removed or changed from one to the other.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			from, to := cmdCfg.envsec.EnvID, cmdCfg.envsec.EnvID
+			from.EnvName, to.EnvName = flags.from, flags.to
+
+			diffs, err := cmdCfg.envsec.Diff(cmd.Context(), from, to)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if flags.ShowValues {
+				names := []string{}
+				for _, diff := range diffs {
+					names = append(names, diff.Name)
+				}
+				cmdCfg.envsec.Audit(cmd.Context(), envsec.AuditList, from, names)
+				cmdCfg.envsec.Audit(cmd.Context(), envsec.AuditList, to, names)
+			}
+			return envsec.PrintDiff(
+				cmd.OutOrStdout(), flags.from, flags.to, diffs, flags.ShowValues, flags.Format)
+		},
+	}
+
+	registerFromTo(command, &flags.from, &flags.to)
+	command.Flags().BoolVarP(
+		&flags.ShowValues,
+		"show",
+		"s",
+		false,
+		"display the values of secrets that differ too. Values of config variables are always shown",
+	)
+	command.Flags().StringVarP(
+		&flags.Format,
+		"format",
+		"f",
+		"table",
+		"format to use for displaying differences, one of: table, json",
+	)
+	flags.configFlags.register(command)
+
+	return command
+}
+
+func registerFromTo(command *cobra.Command, from *string, to *string) {
+	command.Flags().StringVar(from, "from", "", "environment to compare from, such as dev")
+	command.Flags().StringVar(to, "to", "", "environment to compare to, such as prod")
+	_ = command.MarkFlagRequired("from")
+	_ = command.MarkFlagRequired("to")
+}
@@ -4,6 +4,7 @@
 package envcli
 
 import (
+	"github.com/MakeNowJust/heredoc"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"go.jetpack.io/envsec/pkg/envsec"
@@ -11,7 +12,12 @@ import (
 
 type downloadCmdFlags struct {
 	configFlags
-	format string
+	format       string
+	raw          bool
+	sensitivity  string
+	name         string
+	namespace    string
+	withMetadata bool
 }
 
 func DownloadCmd() *cobra.Command {
@@ -19,9 +25,29 @@ func DownloadCmd() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "download <file1>",
 		Short: "Download environment variables into the specified file",
-		Long:  "Download environment variables stored into the specified file (most commonly a .env file). The format of the file is one NAME=VALUE per line.",
-		Args:  cobra.ExactArgs(1),
+		Long: heredoc.Doc(`
+			Download environment variables stored into the specified file (most commonly a .env file). The format of the file is one NAME=VALUE per line.
+
+			With --format k8s-secret, the file is a Kubernetes manifest with a Secret named
+			--name for the secrets, and a ConfigMap named <name>-config for the config.
+
+			The shell and fish formats write commands that export the variables, and the
+			docker and systemd formats write files for docker run --env-file and for the
+			EnvironmentFile= of systemd units. Use - as the file to write to stdout.
+		`),
+		Example: heredoc.Doc(`
+			envsec download .env
+			eval "$(envsec download --format shell -)"
+			envsec download --format docker app.env && docker run --env-file app.env app
+			envsec download --format k8s-secret --name app-secrets --namespace prod secrets.yaml
+		`),
+		Args: cobra.ExactArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if flags.sensitivity != "" {
+				if _, err := envsec.ParseSensitivity(flags.sensitivity); err != nil {
+					return err
+				}
+			}
 			return envsec.ValidateFormat(flags.format)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -29,13 +55,45 @@ func DownloadCmd() *cobra.Command {
 			if err != nil {
 				return errors.WithStack(err)
 			}
-			return cmdCfg.envsec.Download(cmd.Context(), args[0], flags.format)
+			return cmdCfg.envsec.DownloadWithOptions(cmd.Context(), args[0], envsec.DownloadOptions{
+				Format:      flags.format,
+				Raw:         flags.raw,
+				Sensitivity: envsec.Sensitivity(flags.sensitivity),
+				Kubernetes: envsec.KubernetesOptions{
+					Name:      flags.name,
+					Namespace: flags.namespace,
+				},
+				WithMetadata: flags.withMetadata,
+			})
 		},
 	}
 
 	flags.configFlags.register(command)
 	command.Flags().StringVarP(
-		&flags.format, "format", "f", "env", "file format: dotenv or json")
+		&flags.format,
+		"format",
+		"f",
+		"",
+		"file format: dotenv, json, k8s-secret, shell, fish, docker or systemd. Defaults to json for .json files, and to dotenv otherwise",
+	)
+	command.Flags().BoolVar(
+		&flags.withMetadata,
+		"with-metadata",
+		false,
+		"for --format json, write variables with metadata as objects with their value, description, owner, expiry, sensitivity and tags",
+	)
+	command.Flags().StringVar(
+		&flags.name, "name", "", "name of the Kubernetes Secret, for --format k8s-secret")
+	command.Flags().StringVar(
+		&flags.namespace, "namespace", "", "namespace of the Kubernetes objects, for --format k8s-secret")
+	command.Flags().BoolVar(
+		&flags.raw, "raw", false, "download values as stored, even if the project expands ${VAR} references")
+	command.Flags().StringVar(
+		&flags.sensitivity,
+		"sensitivity",
+		"",
+		"download only secrets or only config, one of: secret, config. Config that refers to secrets counts as secret",
+	)
 
 	return command
 }
@@ -4,50 +4,299 @@
 package envcli
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 
+	"github.com/MakeNowJust/heredoc"
+	"github.com/joho/godotenv"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"go.jetpack.io/envsec/pkg/envsec"
 )
 
 type execCmdFlags struct {
 	configFlags
+	raw        bool
+	shell      bool
+	noInherit  bool
+	envFiles   []string
+	env        []string
+	precedence string
+	explain    bool
 }
 
+const (
+	localPrecedence  = "local"
+	remotePrecedence = "remote"
+)
+
 func ExecCmd() *cobra.Command {
 	flags := &execCmdFlags{}
 	command := &cobra.Command{
-		Use:   "exec <command>",
+		Use:   "exec [flags] [--] <command> [args]...",
 		Short: "Execute a command with Jetify-stored environment variables",
-		Long:  "Execute a specified command with remote environment variables being present for the duration of the command. If an environment variable exists both locally and in remote storage, the remotely stored one is prioritized.",
-		Args:  cobra.MinimumNArgs(1),
+		Long: heredoc.Doc(`
+			Execute a specified command with remote environment variables being present
+			for the duration of the command.
+
+			The command's environment is built in layers, each overriding the ones
+			before it:
+
+			  1. envsec's own environment, unless --no-inherit is set
+			  2. the variables stored in the remote environment
+			  3. local override files, .env.local by default (see --env-file)
+			  4. variables given with --env NAME=VALUE
+
+			With --precedence=remote, the remote environment overrides local files
+			instead. Variables given with --env always win. Use --explain to print
+			where each variable comes from without running the command.
+
+			The command is run directly, without a shell, unless --shell is set. Signals
+			sent to envsec are forwarded to the command, and envsec exits with the
+			command's exit code.
+		`),
+		Example: heredoc.Doc(`
+			envsec exec -- npm run dev --port 3000
+			envsec exec --shell 'echo $DATABASE_URL'
+			envsec exec --env LOG_LEVEL=debug -- ./server
+			envsec exec --explain
+		`),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if flags.explain {
+				// Only names are shown, so there's nothing to audit.
+				return nil
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if flags.precedence != localPrecedence && flags.precedence != remotePrecedence {
+				return errors.Errorf(
+					"invalid precedence %q. Must be one of local|remote", flags.precedence)
+			}
+			explicit, err := flags.envFlagLayer()
+			if err != nil {
+				return err
+			}
+
 			cmdCfg, err := flags.genConfig(cmd)
 			if err != nil {
 				return err
 			}
-			commandString := strings.Join(args, " ")
-			commandToRun := exec.Command("/bin/sh", "-c", commandString)
 
 			// Get list of stored env variables
-			envVars, err := cmdCfg.envsec.List(cmd.Context())
+			list := cmdCfg.envsec.ListResolved
+			if flags.raw {
+				list = cmdCfg.envsec.List
+			}
+			envVars, err := list(cmd.Context())
 			if err != nil {
 				return errors.WithStack(err)
 			}
-			// Attach stored env variables to the command environment
-			commandToRun.Env = os.Environ()
+			remote := envLayer{
+				source: "remote (" + cmdCfg.envsec.EnvID.EnvName + ")",
+				vars:   map[string]string{},
+			}
 			for _, envVar := range envVars {
-				commandToRun.Env = append(commandToRun.Env, fmt.Sprintf("%s=%s", envVar.Name, envVar.Value))
+				remote.vars[envVar.Name] = envVar.Value
+			}
+
+			local, err := flags.envFileLayers(cmd, cmdCfg.envsec.WorkingDir)
+			if err != nil {
+				return err
+			}
+
+			layers := []envLayer{}
+			if !flags.noInherit {
+				layers = append(layers, environLayer(inheritedSource, os.Environ()))
 			}
+			if flags.precedence == remotePrecedence {
+				layers = append(layers, local...)
+				layers = append(layers, remote)
+			} else {
+				layers = append(layers, remote)
+				layers = append(layers, local...)
+			}
+			layers = append(layers, explicit)
+			env, origins := layerEnv(layers)
+
+			if flags.explain {
+				return printEnvOrigins(cmd.OutOrStdout(), cmdCfg.envsec.EnvID.EnvName, origins)
+			}
+
+			var commandToRun *exec.Cmd
+			if flags.shell {
+				commandToRun = exec.Command("/bin/sh", "-c", strings.Join(args, " "))
+			} else {
+				commandToRun = exec.Command(args[0], args[1:]...)
+			}
+			commandToRun.Env = env
 			commandToRun.Stdin = cmd.InOrStdin()
 			commandToRun.Stdout = cmd.OutOrStdout()
 			commandToRun.Stderr = cmd.ErrOrStderr()
-			return commandToRun.Run()
+			cmdCfg.envsec.Audit(
+				cmd.Context(), envsec.AuditExec, cmdCfg.envsec.EnvID, envVarNames(envVars))
+			return runCommand(cmd.Context(), commandToRun)
 		},
 	}
+
+	// Flags after the command belong to the command, not to envsec.
+	command.Flags().SetInterspersed(false)
+	command.Flags().BoolVar(
+		&flags.raw, "raw", false, "pass values as stored, even if the project expands ${VAR} references")
+	command.Flags().BoolVar(
+		&flags.shell, "shell", false, "run the command with /bin/sh -c instead of directly")
+	command.Flags().BoolVar(
+		&flags.noInherit,
+		"no-inherit",
+		false,
+		"start from an empty environment instead of envsec's own, so the command only sees stored variables",
+	)
+	command.Flags().StringSliceVar(
+		&flags.envFiles,
+		"env-file",
+		[]string{".env.local"},
+		"dotenv files with local overrides. Missing files are skipped unless given explicitly",
+	)
+	command.Flags().StringArrayVar(
+		&flags.env, "env", nil, "set a variable for the command, as NAME=VALUE. Overrides all other sources")
+	command.Flags().StringVar(
+		&flags.precedence,
+		"precedence",
+		localPrecedence,
+		"which wins when a variable is set both in local files and remotely, one of: local, remote",
+	)
+	command.Flags().BoolVar(
+		&flags.explain,
+		"explain",
+		false,
+		"print where each variable comes from instead of running the command",
+	)
 	flags.configFlags.register(command)
 	return command
 }
+
+// envFileLayers reads the local override files. The default .env.local is
+// optional, but files given with --env-file must exist.
+func (f *execCmdFlags) envFileLayers(cmd *cobra.Command, workingDir string) ([]envLayer, error) {
+	required := cmd.Flags().Changed("env-file")
+	layers := []envLayer{}
+	for _, path := range f.envFiles {
+		fullPath := path
+		if !filepath.IsAbs(path) {
+			fullPath = filepath.Join(workingDir, path)
+		}
+		vars, err := godotenv.Read(fullPath)
+		if errors.Is(err, fs.ErrNotExist) && !required {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", path)
+		}
+		layers = append(layers, envLayer{source: path, vars: vars})
+	}
+	return layers, nil
+}
+
+// envFlagLayer parses the variables given with --env.
+func (f *execCmdFlags) envFlagLayer() (envLayer, error) {
+	layer := envLayer{source: envFlagSource, vars: map[string]string{}}
+	for _, kv := range f.env {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			return envLayer{}, errors.Errorf("invalid --env %q. Must be NAME=VALUE", kv)
+		}
+		layer.vars[name] = value
+	}
+	return layer, nil
+}
+
+// exitCodeError makes envsec exit with the given code. It's returned when a
+// command run by envsec fails, so that envsec's exit code is the command's.
+type exitCodeError struct {
+	code int
+	// err, if set, is printed before exiting.
+	err error
+}
+
+func (e *exitCodeError) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
+// forwardedSignals are the signals that envsec passes on to the command it
+// runs, instead of exiting.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// runCommand runs a command, forwarding signals to it, and returns an
+// *exitCodeError if it fails. See setProcessGroup for which signals are
+// forwarded when envsec runs in a terminal.
+func runCommand(ctx context.Context, command *exec.Cmd) error {
+	ownGroup := setProcessGroup(command)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := command.Start(); err != nil {
+		code := 126 // found but not executable, same as shells
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+			code = 127
+		}
+		return &exitCodeError{code: code, err: err}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if ownGroup {
+					signalProcessGroup(command.Process, sig)
+				} else if sig == syscall.SIGTERM {
+					// Other signals come from the terminal, which sent them
+					// to the command too, and would arrive twice.
+					_ = command.Process.Signal(sig)
+				}
+			case <-ctx.Done():
+				if ownGroup {
+					signalProcessGroup(command.Process, syscall.SIGTERM)
+				} else {
+					_ = command.Process.Signal(syscall.SIGTERM)
+				}
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := command.Wait()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &exitCodeError{code: exitCode(exitErr.ProcessState)}
+	}
+	return errors.WithStack(err)
+}
+
+// exitCode returns the exit code of a process. Like shells, it returns 128
+// plus the signal number for processes killed by a signal.
+func exitCode(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
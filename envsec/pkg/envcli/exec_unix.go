@@ -0,0 +1,49 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+//go:build !windows
+
+package envcli
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// setProcessGroup makes the command start in its own process group, so that
+// signals can be forwarded to every process it starts, and reports whether it
+// did.
+//
+// When envsec runs in the foreground of a terminal, the command stays in
+// envsec's group instead. The terminal then sends keys like Ctrl-C to both,
+// and job control stops and resumes them together on Ctrl-Z and fg. Handing
+// the terminal to a group of its own would leave envsec waiting on a command
+// that Ctrl-Z stopped, with no shell prompt to resume it from.
+func setProcessGroup(command *exec.Cmd) (ownGroup bool) {
+	tty := int(os.Stdin.Fd())
+	if term.IsTerminal(tty) && unix.Getpgrp() == foregroundGroup(tty) {
+		return false
+	}
+	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return true
+}
+
+func foregroundGroup(tty int) int {
+	pgrp, err := unix.IoctlGetInt(tty, unix.TIOCGPGRP)
+	if err != nil {
+		return -1
+	}
+	return pgrp
+}
+
+// signalProcessGroup sends a signal to every process in the group of p,
+// which includes the processes the command started itself.
+func signalProcessGroup(p *os.Process, sig os.Signal) {
+	if s, ok := sig.(syscall.Signal); ok {
+		_ = syscall.Kill(-p.Pid, s)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+//go:build !windows
+
+package envcli
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestRunCommandExitCode(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected int
+	}{
+		{[]string{"sh", "-c", "exit 0"}, 0},
+		{[]string{"sh", "-c", "exit 3"}, 3},
+		{[]string{"sh", "-c", "kill -TERM $$"}, 143},
+		{[]string{"envsec-no-such-command"}, 127},
+	}
+
+	for _, test := range tests {
+		t.Run(test.args[len(test.args)-1], func(t *testing.T) {
+			err := runCommand(context.Background(), exec.Command(test.args[0], test.args[1:]...))
+			code := 0
+			var exitErr *exitCodeError
+			if errors.As(err, &exitErr) {
+				code = exitErr.code
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if code != test.expected {
+				t.Errorf("Expected exit code %d, but got %d", test.expected, code)
+			}
+		})
+	}
+}
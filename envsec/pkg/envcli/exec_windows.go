@@ -0,0 +1,22 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows, where the console already delivers
+// Ctrl-C to every process attached to it.
+func setProcessGroup(*exec.Cmd) (ownGroup bool) {
+	return true
+}
+
+func signalProcessGroup(p *os.Process, sig os.Signal) {
+	// Windows can't deliver signals other than kill to other processes.
+	if sig != os.Interrupt {
+		_ = p.Kill()
+	}
+}
@@ -12,9 +12,6 @@ import (
 	"go.jetify.com/typeid"
 	"go.jetpack.io/envsec/internal/build"
 	"go.jetpack.io/envsec/pkg/envsec"
-	"go.jetpack.io/envsec/pkg/stores/jetstore"
-	"go.jetpack.io/envsec/pkg/stores/ssmstore"
-	"go.jetpack.io/pkg/envvar"
 	"go.jetpack.io/pkg/id"
 )
 
@@ -23,6 +20,7 @@ type configFlags struct {
 	projectID string
 	orgID     string
 	envName   string
+	store     string
 }
 
 func (f *configFlags) register(cmd *cobra.Command) {
@@ -46,6 +44,15 @@ func (f *configFlags) register(cmd *cobra.Command) {
 		"dev",
 		"environment name, one of: dev, preview, prod",
 	)
+
+	cmd.PersistentFlags().StringVar(
+		&f.store,
+		"store",
+		"",
+		"URL of the store to keep secrets in, such as jetify:// (the default), "+
+			"ssm://<region>/<prefix>, file://<path> or vault://<host>/<mount>. "+
+			"Overrides the project's store",
+	)
 }
 
 func (f *configFlags) validateProjectID(orgID id.OrgID) (string, error) {
@@ -95,32 +102,40 @@ func (f *configFlags) genConfig(cmd *cobra.Command) (*CmdConfig, error) {
 	}
 	envsecInstance := defaultEnvsec(cmd, wd)
 
-	if envvar.Bool("ENVSEC_USE_AWS_STORE") {
-		// Legacy, temporary hack to enable the AWS store
-		envsecInstance.Store = &ssmstore.SSMStore{}
-	} else {
-		envsecInstance.Store = &jetstore.JetpackAPIStore{}
-	}
-
-	tok, err := envsecInstance.InitForUser(cmd.Context())
+	envsecInstance.Store, err = envsec.NewStore(f.storeURL(envsecInstance))
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-
-	if tok != nil && f.orgID == "" {
-		f.orgID = tok.IDClaims().OrgID
-	}
-
-	orgID, err := typeid.Parse[id.OrgID](f.orgID)
+	envsecInstance.Auditor, err = auditSink(envsecInstance)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	projectID, err := f.validateProjectID(orgID)
+	tok, err := envsecInstance.InitForUser(cmd.Context())
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
+	var projectID string
+	if tok == nil {
+		// Stores that work offline don't need a Jetify project.
+		projectID = f.offlineProjectID(envsecInstance)
+	} else {
+		if f.orgID == "" {
+			f.orgID = tok.IDClaims().OrgID
+		}
+
+		orgID, err := typeid.Parse[id.OrgID](f.orgID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		projectID, err = f.validateProjectID(orgID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
 	envid, err := envsec.NewEnvID(projectID, f.orgID, f.envName)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -139,6 +154,22 @@ func (f *configFlags) genConfig(cmd *cobra.Command) (*CmdConfig, error) {
 	}, nil
 }
 
+// offlineProjectID returns the project ID for stores that don't need a
+// Jetify project. The project doesn't have to be initialized.
+func (f *configFlags) offlineProjectID(e *envsec.Envsec) string {
+	config, err := e.ProjectConfig()
+	if err == nil && f.orgID == "" && config.OrgID != (id.OrgID{}) {
+		f.orgID = config.OrgID.String()
+	}
+	if f.projectID != "" {
+		return f.projectID
+	}
+	if err == nil && config.ProjectID != (id.ProjectID{}) {
+		return config.ProjectID.String()
+	}
+	return "default"
+}
+
 var bootstrappedConfig *CmdConfig
 
 // BootstrapConfig is used to set the config for all commands that use genConfig
@@ -0,0 +1,68 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetpack.io/envsec/pkg/envsec"
+)
+
+type historyCmdFlags struct {
+	configFlags
+	ShowValues bool
+	Format     string
+}
+
+func HistoryCmd() *cobra.Command {
+	flags := &historyCmdFlags{}
+	command := &cobra.Command{
+		Use:   "history <NAME>",
+		Short: "List the previous versions of an environment variable",
+		Long:  "List the previous versions of an environment variable, with when and by whom they were set. Only stores that keep history support this.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			versions, err := cmdCfg.envsec.History(cmd.Context(), args[0])
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if flags.ShowValues {
+				cmdCfg.envsec.Audit(
+					cmd.Context(), envsec.AuditList, cmdCfg.envsec.EnvID, []string{args[0]})
+			}
+
+			return envsec.PrintHistory(
+				cmd.OutOrStdout(),
+				cmdCfg.envsec.EnvID,
+				args[0],
+				versions,
+				flags.ShowValues,
+				flags.Format,
+			)
+		},
+	}
+
+	command.Flags().BoolVarP(
+		&flags.ShowValues,
+		"show",
+		"s",
+		false,
+		"display the value of each version (secrets included)",
+	)
+	command.Flags().StringVarP(
+		&flags.Format,
+		"format",
+		"f",
+		"table",
+		"format to use for displaying versions, one of: table, json",
+	)
+	flags.configFlags.register(command)
+
+	return command
+}
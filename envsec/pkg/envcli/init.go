@@ -52,6 +52,7 @@ func defaultEnvsec(cmd *cobra.Command, workingDir string) *envsec.Envsec {
 		},
 		IsDev:      build.IsDev,
 		Stderr:     cmd.ErrOrStderr(),
+		Stdout:     cmd.OutOrStdout(),
 		WorkingDir: workingDir,
 	}
 }
@@ -0,0 +1,106 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"go.jetpack.io/envsec/internal/tux"
+)
+
+// Sources of the layers of an environment, as shown by exec --explain.
+const (
+	inheritedSource = "inherited"
+	envFlagSource   = "--env"
+)
+
+// envLayer holds the variables that one source, such as the remote
+// environment or a .env.local file, contributes to a command's environment.
+type envLayer struct {
+	source string
+	vars   map[string]string
+}
+
+// envOrigin records where a variable of a layered environment came from.
+type envOrigin struct {
+	name   string
+	source string
+	// overrides lists the lower layers that also set the variable, from the
+	// lowest up.
+	overrides []string
+}
+
+// layerEnv merges layers into an environment, with later layers winning. It
+// returns the environment as NAME=VALUE pairs along with the origin of each
+// variable, both sorted by name.
+func layerEnv(layers []envLayer) ([]string, []envOrigin) {
+	values := map[string]string{}
+	origins := map[string]*envOrigin{}
+	for _, layer := range layers {
+		for name, value := range layer.vars {
+			values[name] = value
+			if origin, ok := origins[name]; ok {
+				origin.overrides = append(origin.overrides, origin.source)
+				origin.source = layer.source
+			} else {
+				origins[name] = &envOrigin{name: name, source: layer.source}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := make([]string, 0, len(names))
+	explained := make([]envOrigin, 0, len(names))
+	for _, name := range names {
+		env = append(env, name+"="+values[name])
+		explained = append(explained, *origins[name])
+	}
+	return env, explained
+}
+
+// environLayer turns NAME=VALUE pairs, as returned by os.Environ, into a layer.
+func environLayer(source string, environ []string) envLayer {
+	layer := envLayer{source: source, vars: map[string]string{}}
+	for _, kv := range environ {
+		// Windows has variables such as =C:, whose names start with =.
+		i := strings.Index(kv[min(1, len(kv)):], "=") + 1
+		if i <= 0 {
+			continue
+		}
+		layer.vars[kv[:i]] = kv[i+1:]
+	}
+	return layer
+}
+
+// printEnvOrigins prints where each variable came from. Variables that were
+// only inherited from envsec's own environment are left out.
+func printEnvOrigins(w io.Writer, envName string, origins []envOrigin) error {
+	err := tux.WriteHeader(w, "Environment: %s\n", strings.ToLower(envName))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Name", "Source", "Overrides"})
+	for _, origin := range origins {
+		if origin.source == inheritedSource {
+			continue
+		}
+		table.Append([]string{origin.name, origin.source, strings.Join(origin.overrides, ", ")})
+	}
+	if table.NumLines() == 0 {
+		_, err = io.WriteString(w, "No environment variables are set by envsec.\n")
+		return errors.WithStack(err)
+	}
+	table.Render()
+	return nil
+}
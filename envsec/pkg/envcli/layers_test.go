@@ -0,0 +1,34 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLayerEnv(t *testing.T) {
+	env, origins := layerEnv([]envLayer{
+		environLayer(inheritedSource, []string{"PATH=/bin", "PORT=80", "=C:=C:\\", "EMPTY="}),
+		{source: "remote (dev)", vars: map[string]string{"PORT": "8080", "DB": "remote"}},
+		{source: ".env.local", vars: map[string]string{"DB": "local"}},
+		{source: envFlagSource, vars: map[string]string{"PORT": "3000"}},
+	})
+
+	expectedEnv := []string{"=C:=C:\\", "DB=local", "EMPTY=", "PATH=/bin", "PORT=3000"}
+	if !reflect.DeepEqual(env, expectedEnv) {
+		t.Errorf("Expected %v, but got %v", expectedEnv, env)
+	}
+
+	expectedOrigins := []envOrigin{
+		{name: "=C:", source: inheritedSource},
+		{name: "DB", source: ".env.local", overrides: []string{"remote (dev)"}},
+		{name: "EMPTY", source: inheritedSource},
+		{name: "PATH", source: inheritedSource},
+		{name: "PORT", source: envFlagSource, overrides: []string{inheritedSource, "remote (dev)"}},
+	}
+	if !reflect.DeepEqual(origins, expectedOrigins) {
+		t.Errorf("Expected %v, but got %v", expectedOrigins, origins)
+	}
+}
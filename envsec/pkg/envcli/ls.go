@@ -4,6 +4,10 @@
 package envcli
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
 	"go.jetpack.io/envsec/pkg/envsec"
 )
@@ -23,8 +27,16 @@ func ListCmd() *cobra.Command {
 		Use:     "ls",
 		Aliases: []string{"list"},
 		Short:   "List all stored environment variables",
-		Long:    "List all stored environment variables. If no environment flag is provided, variables in all environments will be listed.",
-		Args:    cobra.NoArgs,
+		Long: heredoc.Doc(`
+			List all stored environment variables. If no environment flag is provided, variables in all environments will be listed.
+
+			In projects with rotation policies, a Stale column flags the variables that are
+			older than their max_age. Finding them reads when each variable last changed:
+			with the vault store, that's a request per version of the environment's secret,
+			so ls gets slower as versions pile up. Stores that don't record changes, such
+			as the default Jetify store, can't find stale variables.
+		`),
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			cmdCfg, err := flags.genConfig(cmd)
 			if err != nil {
@@ -35,9 +47,33 @@ func ListCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if flags.ShowValues {
+				cmdCfg.envsec.Audit(
+					cmd.Context(), envsec.AuditList, cmdCfg.envsec.EnvID, envVarNames(secrets))
+			}
+
+			policies, err := cmdCfg.envsec.RotationPolicies()
+			if err != nil {
+				return err
+			}
+			// Only projects with rotation policies get a Stale column.
+			var stale map[string]time.Duration
+			if len(policies) > 0 {
+				if stale, err = cmdCfg.envsec.StaleVars(cmd.Context(), policies); err != nil {
+					return err
+				}
+				if stale == nil {
+					fmt.Fprintln(cmd.ErrOrStderr(),
+						"Warning: this store doesn't record when variables change, so stale secrets can't be found")
+				}
+			}
 
-			return envsec.PrintEnvVar(
-				cmd.OutOrStdout(), cmdCfg.envsec.EnvID, secrets, flags.ShowValues, flags.Format)
+			return envsec.PrintEnvVarWithOptions(
+				cmd.OutOrStdout(),
+				cmdCfg.envsec.EnvID,
+				secrets,
+				envsec.PrintOptions{Expose: flags.ShowValues, Format: flags.Format, Stale: stale},
+			)
 		},
 	}
 
@@ -46,14 +82,14 @@ func ListCmd() *cobra.Command {
 		"show",
 		"s",
 		false,
-		"display the value of each environment variable (secrets included)",
+		"display the values of secrets too. Values of config variables are always shown",
 	)
 	command.Flags().StringVarP(
 		&flags.Format,
 		"format",
 		"f",
 		"table",
-		"format to use for displaying keys and values, one of: table, dotenv, json",
+		"format to use for displaying keys and values, one of: table, dotenv, json, shell, fish, docker, systemd",
 	)
 	flags.configFlags.register(command)
 
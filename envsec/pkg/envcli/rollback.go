@@ -0,0 +1,42 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type rollbackCmdFlags struct {
+	configFlags
+	version int
+}
+
+func RollbackCmd() *cobra.Command {
+	flags := &rollbackCmdFlags{}
+	command := &cobra.Command{
+		Use:   "rollback <NAME> --to <version>",
+		Short: "Restore an environment variable to a previous version",
+		Long:  "Restore an environment variable to the value it had at a previous version, as listed by `envsec history`. The restored value is stored as a new version.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			return cmdCfg.envsec.Rollback(cmd.Context(), args[0], flags.version)
+		},
+	}
+
+	command.Flags().IntVar(
+		&flags.version,
+		"to",
+		0,
+		"version to restore",
+	)
+	_ = command.MarkFlagRequired("to")
+	flags.configFlags.register(command)
+
+	return command
+}
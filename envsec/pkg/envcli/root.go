@@ -6,6 +6,7 @@ package envcli
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -51,15 +52,22 @@ func RootCmd(flags *rootCmdFlags) *cobra.Command {
 	)
 	command.Flag("json-errors").Hidden = true
 
+	command.AddCommand(ApplyCmd())
+	command.AddCommand(AuditCmd())
 	command.AddCommand(authCmd())
+	command.AddCommand(DiffCmd())
 	command.AddCommand(DownloadCmd())
 	command.AddCommand(ExecCmd())
 	command.AddCommand(genDocsCmd())
+	command.AddCommand(HistoryCmd())
 	command.AddCommand(initCmd())
 	command.AddCommand(ListCmd())
 	command.AddCommand(infoCmd())
 	command.AddCommand(RemoveCmd())
+	command.AddCommand(RollbackCmd())
+	command.AddCommand(RotateCmd())
 	command.AddCommand(SetCmd())
+	command.AddCommand(SyncCmd())
 	command.AddCommand(UploadCmd())
 	command.AddCommand(versionCmd())
 	command.SetUsageFunc(UsageFunc)
@@ -73,6 +81,11 @@ func Execute(ctx context.Context) int {
 	if err == nil {
 		return 0
 	}
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) && exitErr.err == nil {
+		// The command run by envsec already reported its own failure.
+		return exitErr.code
+	}
 	if flags.jsonErrors {
 		var jsonErr struct {
 			Error string `json:"error"`
@@ -87,5 +100,8 @@ func Execute(ctx context.Context) int {
 	} else {
 		fmt.Fprintln(os.Stderr, err)
 	}
+	if exitErr != nil {
+		return exitErr.code
+	}
 	return 1
 }
@@ -0,0 +1,129 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"sort"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetpack.io/envsec/internal/tux"
+)
+
+type rotateCmdFlags struct {
+	configFlags
+	stale  bool
+	random bool
+}
+
+func RotateCmd() *cobra.Command {
+	flags := &rotateCmdFlags{}
+	command := &cobra.Command{
+		Use:   "rotate <NAME>...",
+		Short: "Replace environment variables with newly generated values",
+		Long: heredoc.Doc(`
+			Replace environment variables with newly generated values, and run the
+			hooks of their rotation policies.
+
+			Policies are set in the "rotation" field of .jetify/project.json:
+
+			  "rotation": {
+			    "DB_PASSWORD": {
+			      "max_age": "30d",
+			      "generate": "openssl rand -hex 24",
+			      "hooks": [
+			        {"command": "./scripts/set-db-password.sh"},
+			        {"webhook": "https://deploy.example.com/hooks/redeploy"}
+			      ]
+			    }
+			  }
+
+			Without a generate command, a random value of "length" (32 by default)
+			letters and digits is generated. Variables without a policy are only
+			rotated with --random, which replaces them with 32 random letters and
+			digits and runs no hooks. Commands get the variable's name in
+			ENVSEC_NAME and its environment in ENVSEC_ENVIRONMENT, and hook commands
+			get the new value in ENVSEC_VALUE. Webhooks are sent the name, but not
+			the value.
+		`),
+		Example: heredoc.Doc(`
+			envsec rotate DB_PASSWORD --environment prod
+			envsec rotate --stale
+			envsec rotate SESSION_KEY --random
+		`),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if flags.stale && len(args) > 0 {
+				return errors.New("--stale rotates all stale variables, so it can't be combined with names")
+			} else if flags.stale {
+				return nil
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			policies, err := cmdCfg.envsec.RotationPolicies()
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			names := args
+			if flags.stale {
+				stale, err := cmdCfg.envsec.StaleVars(cmd.Context(), policies)
+				if err != nil {
+					return errors.WithStack(err)
+				} else if stale == nil {
+					return errors.New("this store doesn't record when variables change, so it can't find stale ones")
+				}
+				for name := range stale {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				if len(names) == 0 {
+					return tux.WriteHeader(cmd.ErrOrStderr(),
+						"[DONE] No stale environment variables in environment: %s\n",
+						cmdCfg.envsec.EnvID.EnvName,
+					)
+				}
+			}
+
+			// Check every name first, so that nothing is rotated if one of
+			// them can't be.
+			for _, name := range names {
+				if _, ok := policies[name]; !ok && !flags.random {
+					return errors.Errorf(
+						"%s has no rotation policy in .jetify/project.json. Add one, or pass "+
+							"--random to replace it with a random value without running any hooks",
+						name,
+					)
+				}
+			}
+			for _, name := range names {
+				if err := cmdCfg.envsec.Rotate(cmd.Context(), name, policies[name]); err != nil {
+					return errors.WithStack(err)
+				}
+			}
+			return nil
+		},
+	}
+
+	command.Flags().BoolVar(
+		&flags.stale,
+		"stale",
+		false,
+		"rotate every variable that's older than its policy's max_age",
+	)
+	command.Flags().BoolVar(
+		&flags.random,
+		"random",
+		false,
+		"replace variables that have no rotation policy with a random value",
+	)
+	flags.configFlags.register(command)
+
+	return command
+}
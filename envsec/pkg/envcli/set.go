@@ -4,6 +4,10 @@
 package envcli
 
 import (
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"go.jetpack.io/envsec/pkg/envsec"
@@ -11,6 +15,11 @@ import (
 
 type setCmdFlags struct {
 	configFlags
+	description string
+	owner       string
+	expires     string
+	sensitivity string
+	tags        []string
 }
 
 func SetCmd() *cobra.Command {
@@ -18,21 +27,88 @@ func SetCmd() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "set <NAME1>=<value1> [<NAME2>=<value2>]...",
 		Short: "Securely store one or more environment variables",
-		Long:  "Securely store one or more environment variables. To test contents of a file as a secret use set=@<file>",
-		Args:  cobra.MinimumNArgs(1),
+		Long: heredoc.Doc(`
+			Securely store one or more environment variables. To test contents of a file as a secret use set=@<file>
+
+			The --desc, --owner, --expires, --sensitivity and --tag flags add metadata to
+			the variables, which envsec ls shows. Metadata that isn't given is kept.
+		`),
+		Example: heredoc.Doc(`
+			envsec set DB_PASSWORD=hunter2 --desc "Password of the app's database user" --owner db-team
+			envsec set LOG_LEVEL=debug --sensitivity config --tag team=platform
+		`),
+		Args: cobra.MinimumNArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return envsec.ValidateSetArgs(args)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
+			metadata, err := flags.metadata()
+			if err != nil {
+				return err
+			}
 			cmdCfg, err := flags.genConfig(cmd)
 			if err != nil {
 				return errors.WithStack(err)
 			}
 
-			return cmdCfg.envsec.SetFromArgs(ctx, args)
+			return cmdCfg.envsec.SetFromArgsWithMetadata(ctx, args, metadata)
 		},
 	}
+	command.Flags().StringVar(
+		&flags.description, "desc", "", "description of the variables")
+	command.Flags().StringVar(
+		&flags.owner, "owner", "", "who owns the variables, such as a team or an email")
+	command.Flags().StringVar(
+		&flags.expires, "expires", "", "when the values expire, as a date such as 2025-12-31")
+	command.Flags().StringVar(
+		&flags.sensitivity, "sensitivity", "", "whether the variables are secrets, one of: secret, config")
+	command.Flags().StringArrayVar(
+		&flags.tags, "tag", nil, "add a tag, as KEY=VALUE or KEY. Can be repeated")
 	flags.configFlags.register(command)
 	return command
 }
+
+func (f *setCmdFlags) metadata() (envsec.EnvVarMetadata, error) {
+	metadata := envsec.EnvVarMetadata{
+		Description: f.description,
+		Owner:       f.owner,
+	}
+	if f.expires != "" {
+		expires, err := parseDate(f.expires)
+		if err != nil {
+			return envsec.EnvVarMetadata{}, err
+		}
+		metadata.Expires = &expires
+	}
+	if f.sensitivity != "" {
+		sensitivity, err := envsec.ParseSensitivity(f.sensitivity)
+		if err != nil {
+			return envsec.EnvVarMetadata{}, err
+		}
+		metadata.Sensitivity = sensitivity
+	}
+	for _, tag := range f.tags {
+		key, value, _ := strings.Cut(tag, "=")
+		if key == "" {
+			return envsec.EnvVarMetadata{}, errors.Errorf("invalid tag %q. Must be KEY=VALUE or KEY", tag)
+		}
+		if metadata.Tags == nil {
+			metadata.Tags = map[string]string{}
+		}
+		metadata.Tags[key] = value
+	}
+	return metadata, nil
+}
+
+// parseDate parses a date such as 2025-12-31, or a time in RFC 3339 format.
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid date %q. Use a date such as 2025-12-31", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/envsec/pkg/envsec"
+	"go.jetpack.io/envsec/pkg/stores/filestore"
+	"go.jetpack.io/envsec/pkg/stores/jetstore"
+	"go.jetpack.io/envsec/pkg/stores/ssmstore"
+	"go.jetpack.io/envsec/pkg/stores/vaultstore"
+	"go.jetpack.io/pkg/envvar"
+)
+
+const defaultStore = "jetify://"
+
+// Built-in stores. Programs that embed the CLI can register more with
+// envsec.RegisterStore before calling Execute.
+func init() {
+	envsec.RegisterStore("jetify", newJetifyStore)
+	envsec.RegisterStore("ssm", newSSMStore)
+	envsec.RegisterStore("file", newFileStore)
+	envsec.RegisterStore("vault", newVaultStore)
+}
+
+// jetify:// stores secrets with the Jetify API. jetify://?metadata=true also
+// keeps the metadata of variables, which the API has no native support for.
+func newJetifyStore(u *url.URL) (envsec.Store, error) {
+	if u.Host != "" || strings.Trim(u.Path, "/") != "" {
+		return nil, errors.New("jetify:// takes no host or path")
+	}
+	if u.Query().Get("metadata") == "true" {
+		return &jetstore.JetpackAPIMetadataStore{}, nil
+	}
+	return &jetstore.JetpackAPIStore{}, nil
+}
+
+// ssm:// stores secrets in AWS Parameter Store, using credentials from the
+// user's Jetify account. ssm://<region>/<prefix> uses the default AWS
+// credential chain instead, and stores secrets under <prefix>, which
+// defaults to /jetpack-data/env
+func newSSMStore(u *url.URL) (envsec.Store, error) {
+	if u.Host == "" {
+		if strings.Trim(u.Path, "/") != "" {
+			return nil, errors.New("ssm:// needs a region before the path prefix")
+		}
+		return &ssmstore.SSMStore{}, nil
+	}
+	config := &ssmstore.SSMConfig{
+		Region:   u.Host,
+		KmsKeyID: u.Query().Get("kms_key_id"),
+	}
+	if prefix := strings.Trim(u.Path, "/"); prefix != "" {
+		config.PathPrefix = path.Join("/", prefix)
+	}
+	return &ssmstore.SSMStore{Config: config}, nil
+}
+
+// file:// stores secrets in the user's config directory, and file://<path>
+// in an encrypted file at path, relative to the working directory unless it
+// starts with a slash (file:///abs/path).
+func newFileStore(u *url.URL) (envsec.Store, error) {
+	filePath := u.Opaque
+	if filePath == "" {
+		filePath = u.Host + u.Path
+	}
+	return &filestore.FileStore{Path: filePath}, nil
+}
+
+// vault://<host>[:<port>]/<mount> stores secrets in Vault's KV v2 secrets
+// engine. Without a host the address comes from $VAULT_ADDR. Vault is
+// reached over https unless tls=false, and the namespace and jwt_role query
+// parameters set the namespace and the role to log in with.
+func newVaultStore(u *url.URL) (envsec.Store, error) {
+	query := u.Query()
+	config := vaultstore.VaultConfig{
+		Mount:     strings.Trim(u.Path, "/"),
+		Namespace: query.Get("namespace"),
+		JWTRole:   query.Get("jwt_role"),
+	}
+	if u.Host != "" {
+		scheme := "https"
+		if query.Get("tls") == "false" {
+			scheme = "http"
+		}
+		config.Address = scheme + "://" + u.Host
+	}
+	return &vaultstore.VaultStore{Config: config}, nil
+}
+
+// storeURL returns the URL of the store to use: the one given with --store,
+// or else the project's, or else the Jetify API.
+func (f *configFlags) storeURL(e *envsec.Envsec) string {
+	if f.store != "" {
+		return f.store
+	}
+	if config, err := e.ProjectConfig(); err == nil && config.Store != "" {
+		return config.Store
+	}
+	if envvar.Bool("ENVSEC_USE_AWS_STORE") {
+		// Deprecated, kept so existing setups don't break. Use --store ssm://
+		return "ssm://"
+	}
+	return defaultStore
+}
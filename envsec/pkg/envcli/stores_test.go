@@ -0,0 +1,69 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"reflect"
+	"testing"
+
+	"go.jetpack.io/envsec/pkg/envsec"
+	"go.jetpack.io/envsec/pkg/stores/filestore"
+	"go.jetpack.io/envsec/pkg/stores/jetstore"
+	"go.jetpack.io/envsec/pkg/stores/ssmstore"
+	"go.jetpack.io/envsec/pkg/stores/vaultstore"
+)
+
+func TestNewStore(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected envsec.Store
+	}{
+		{"jetify://", &jetstore.JetpackAPIStore{}},
+		{"jetify", &jetstore.JetpackAPIStore{}},
+		{"jetify://?metadata=true", &jetstore.JetpackAPIMetadataStore{}},
+		{"ssm://", &ssmstore.SSMStore{}},
+		{"ssm://us-east-1", &ssmstore.SSMStore{Config: &ssmstore.SSMConfig{Region: "us-east-1"}}},
+		{"ssm://us-east-1/team/env?kms_key_id=alias/envsec", &ssmstore.SSMStore{Config: &ssmstore.SSMConfig{
+			Region:     "us-east-1",
+			PathPrefix: "/team/env",
+			KmsKeyID:   "alias/envsec",
+		}}},
+		{"file", &filestore.FileStore{}},
+		{"file://secrets.age", &filestore.FileStore{Path: "secrets.age"}},
+		{"file://config/secrets.age", &filestore.FileStore{Path: "config/secrets.age"}},
+		{"file:///etc/secrets.age", &filestore.FileStore{Path: "/etc/secrets.age"}},
+		{"vault://", &vaultstore.VaultStore{}},
+		{"vault://vault.internal:8200/kv?namespace=team", &vaultstore.VaultStore{Config: vaultstore.VaultConfig{
+			Address:   "https://vault.internal:8200",
+			Mount:     "kv",
+			Namespace: "team",
+		}}},
+		{"vault://localhost:8200?tls=false&jwt_role=dev", &vaultstore.VaultStore{Config: vaultstore.VaultConfig{
+			Address: "http://localhost:8200",
+			JWTRole: "dev",
+		}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			result, err := envsec.NewStore(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("Expected %#v, but got %#v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestNewStoreErrors(t *testing.T) {
+	for _, input := range []string{"s3://bucket", "jetify://host", "ssm:///prefix"} {
+		t.Run(input, func(t *testing.T) {
+			if _, err := envsec.NewStore(input); err == nil {
+				t.Errorf("Expected an error for %s", input)
+			}
+		})
+	}
+}
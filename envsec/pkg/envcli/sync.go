@@ -0,0 +1,103 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envcli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetpack.io/envsec/internal/tux"
+	"go.jetpack.io/envsec/pkg/envsec"
+)
+
+type syncCmdFlags struct {
+	configFlags
+	from   string
+	to     string
+	only   []string
+	prune  bool
+	dryRun bool
+}
+
+func SyncCmd() *cobra.Command {
+	flags := &syncCmdFlags{}
+	command := &cobra.Command{
+		Use:   "sync --from <environment> --to <environment>",
+		Short: "Copy environment variables from one environment to another",
+		Long:  "Copy environment variables from one environment to another, such as to promote configuration from dev to prod. Variables that are only set in the target environment are kept, unless --prune is set.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmdCfg, err := flags.genConfig(cmd)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			from, to := cmdCfg.envsec.EnvID, cmdCfg.envsec.EnvID
+			from.EnvName, to.EnvName = flags.from, flags.to
+
+			changes, err := cmdCfg.envsec.Sync(cmd.Context(), from, to, envsec.SyncOptions{
+				Only:   flags.only,
+				Prune:  flags.prune,
+				DryRun: flags.dryRun,
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			return printSyncChanges(cmd, flags, changes)
+		},
+	}
+
+	registerFromTo(command, &flags.from, &flags.to)
+	command.Flags().StringSliceVar(
+		&flags.only,
+		"only",
+		nil,
+		"comma separated names of the variables to sync. Defaults to all",
+	)
+	command.Flags().BoolVar(
+		&flags.prune,
+		"prune",
+		false,
+		"delete variables that are set in the target environment but not in the source",
+	)
+	command.Flags().BoolVar(
+		&flags.dryRun,
+		"dry-run",
+		false,
+		"show what would change without changing anything",
+	)
+	flags.configFlags.register(command)
+
+	return command
+}
+
+func printSyncChanges(cmd *cobra.Command, flags *syncCmdFlags, changes []envsec.EnvVarDiff) error {
+	w := cmd.ErrOrStderr()
+	from, to := strings.ToLower(flags.from), strings.ToLower(flags.to)
+	if len(changes) == 0 {
+		return tux.WriteHeader(w, "[DONE] Environment %s is already in sync with %s\n", to, from)
+	}
+
+	verb := map[envsec.ChangeKind]string{
+		envsec.Added:   "add",
+		envsec.Changed: "update",
+		envsec.Removed: "delete",
+	}
+	for _, change := range changes {
+		if _, err := fmt.Fprintf(w, "  %s %s\n", verb[change.Kind], change.Name); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if flags.dryRun {
+		return tux.WriteHeader(w,
+			"[DRY RUN] Would sync %d %s from %s to %s\n",
+			len(changes), tux.Plural(changes, "variable", "variables"), from, to,
+		)
+	}
+	return tux.WriteHeader(w,
+		"[DONE] Synced %d %s from %s to %s\n",
+		len(changes), tux.Plural(changes, "variable", "variables"), from, to,
+	)
+}
@@ -22,7 +22,7 @@ func UploadCmd() *cobra.Command {
 			"should have one NAME=VALUE per line.",
 		Args: cobra.MinimumNArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return envsec.ValidateFormat(flags.format)
+			return envsec.ValidateUploadFormat(flags.format)
 		},
 		RunE: func(cmd *cobra.Command, paths []string) error {
 			cmdCfg, err := flags.genConfig(cmd)
@@ -35,7 +35,7 @@ func UploadCmd() *cobra.Command {
 	}
 
 	command.Flags().StringVarP(
-		&flags.format, "format", "f", "", "File format: dotenv or json")
+		&flags.format, "format", "f", "", "File format: dotenv, json or k8s-secret")
 	flags.configFlags.register(command)
 
 	return command
@@ -0,0 +1,292 @@
+package envsec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+)
+
+// AuditAction is an operation on variables that's recorded in the audit log.
+type AuditAction string
+
+const (
+	AuditSet    AuditAction = "set"
+	AuditDelete AuditAction = "delete"
+	// AuditList is recorded when values are shown, such as by ls --show.
+	AuditList     AuditAction = "list"
+	AuditDownload AuditAction = "download"
+	AuditExec     AuditAction = "exec"
+	// AuditApply is recorded when values are exported to Kubernetes.
+	AuditApply AuditAction = "apply"
+	// AuditReference is recorded for other environments whose values are read
+	// to expand references, such as ${prod:DB_HOST}.
+	AuditReference AuditAction = "reference"
+	// AuditSync is recorded for the environment that sync copies values from.
+	AuditSync AuditAction = "sync"
+)
+
+// AuditEvent records who read or changed which variables, and when. It never
+// holds values.
+type AuditEvent struct {
+	Time   time.Time   `json:"time"`
+	Action AuditAction `json:"action"`
+	// User is the ID of the Jetify user, or the local user name for stores
+	// that don't need a Jetify account.
+	User        string   `json:"user"`
+	Email       string   `json:"email,omitempty"`
+	OrgID       string   `json:"org_id,omitempty"`
+	ProjectID   string   `json:"project_id"`
+	Environment string   `json:"environment"`
+	Names       []string `json:"names"`
+}
+
+// AuditSink receives audit events.
+type AuditSink interface {
+	WriteAuditEvent(ctx context.Context, event AuditEvent) error
+}
+
+// Audit records that action was performed on the named variables of envID.
+// It does nothing if e.Auditor isn't set. Events that can't be written are
+// reported on e.Stderr rather than failing the operation that was audited.
+func (e *Envsec) Audit(ctx context.Context, action AuditAction, envID EnvID, names []string) {
+	if e.Auditor == nil {
+		return
+	}
+	names = append([]string{}, names...)
+	sort.Strings(names)
+	event := AuditEvent{
+		Time:        time.Now().UTC(),
+		Action:      action,
+		OrgID:       envID.OrgID,
+		ProjectID:   envID.ProjectID,
+		Environment: strings.ToLower(envID.EnvName),
+		Names:       names,
+	}
+	if e.token != nil {
+		claims := e.token.IDClaims()
+		event.User = claims.Subject
+		event.Email = claims.Email
+	} else if u, err := user.Current(); err == nil {
+		event.User = u.Username
+	}
+
+	if err := e.Auditor.WriteAuditEvent(ctx, event); err != nil && e.Stderr != nil {
+		fmt.Fprintf(e.Stderr, "Warning: failed to write audit event: %v\n", err)
+	}
+}
+
+// NewAuditSink returns the sink for a URL: file://<path> appends events to a
+// JSONL file, and http:// or https:// URLs receive each event as a POST.
+func NewAuditSink(rawURL string) (AuditSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid audit sink URL %q", rawURL)
+	}
+	switch u.Scheme {
+	case "file":
+		path := u.Opaque
+		if path == "" {
+			path = u.Host + u.Path
+		}
+		if path == "" {
+			return nil, errors.Errorf("audit sink %q has no path", rawURL)
+		}
+		return &FileAuditSink{Path: path}, nil
+	case "http", "https":
+		return &WebhookAuditSink{URL: rawURL}, nil
+	}
+	return nil, errors.Errorf("unsupported audit sink %q. Must be one of file://, http:// or https://", rawURL)
+}
+
+// MultiAuditSink writes events to each of its sinks.
+type MultiAuditSink []AuditSink
+
+func (m MultiAuditSink) WriteAuditEvent(ctx context.Context, event AuditEvent) error {
+	var firstErr error
+	for _, sink := range m {
+		if err := sink.WriteAuditEvent(ctx, event); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// DefaultAuditLogPath returns the path of the local audit log, which is
+// queried by envsec audit.
+func DefaultAuditLogPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return filepath.Join(dir, "envsec", "audit.jsonl"), nil
+}
+
+// FileAuditSink appends events to a file, one JSON object per line.
+type FileAuditSink struct {
+	Path string
+}
+
+func (f *FileAuditSink) WriteAuditEvent(_ context.Context, event AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o700); err != nil {
+		return errors.WithStack(err)
+	}
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// A single write keeps lines from concurrent envsec processes whole.
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(file.Close())
+}
+
+// WebhookAuditSink POSTs each event as JSON to a URL.
+type WebhookAuditSink struct {
+	URL string
+	// HTTPClient sends the requests. If nil, a client with a short timeout is
+	// used, so that a slow webhook doesn't hold up envsec.
+	HTTPClient *http.Client
+}
+
+func (w *WebhookAuditSink) WriteAuditEvent(ctx context.Context, event AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(data))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("audit webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// AuditFilter selects events from the audit log. Zero fields match all
+// events.
+type AuditFilter struct {
+	Since       time.Time
+	Action      AuditAction
+	User        string
+	ProjectID   string
+	Environment string
+	Name        string
+}
+
+func (f AuditFilter) matches(event AuditEvent) bool {
+	switch {
+	case !f.Since.IsZero() && event.Time.Before(f.Since),
+		f.Action != "" && event.Action != f.Action,
+		f.User != "" && event.User != f.User && event.Email != f.User,
+		f.ProjectID != "" && event.ProjectID != f.ProjectID,
+		f.Environment != "" && !strings.EqualFold(event.Environment, f.Environment):
+		return false
+	}
+	if f.Name == "" {
+		return true
+	}
+	for _, name := range event.Names {
+		if name == f.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadAuditLog reads the events of a JSONL audit log that match filter, oldest
+// first. A log that doesn't exist has no events.
+func ReadAuditLog(path string, filter AuditFilter) ([]AuditEvent, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []AuditEvent{}, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer file.Close()
+
+	events := []AuditEvent{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, errors.Wrapf(err, "invalid audit event on line %d of %s", line, path)
+		}
+		if filter.matches(event) {
+			events = append(events, event)
+		}
+	}
+	return events, errors.WithStack(scanner.Err())
+}
+
+// PrintAuditEvents prints audit events as a table or as JSON.
+func PrintAuditEvents(w io.Writer, events []AuditEvent, format string) error {
+	switch format {
+	case "table":
+		if len(events) == 0 {
+			_, err := io.WriteString(w, "No audit events found.\n")
+			return errors.WithStack(err)
+		}
+		table := tablewriter.NewWriter(w)
+		table.SetHeader([]string{"Time", "User", "Action", "Environment", "Variables"})
+		for _, event := range events {
+			user := event.User
+			if event.Email != "" {
+				user = event.Email
+			}
+			table.Append([]string{
+				event.Time.Local().Format(time.DateTime),
+				user,
+				string(event.Action),
+				event.Environment,
+				strings.Join(event.Names, ", "),
+			})
+		}
+		table.Render()
+		return nil
+	case "json":
+		data, err := json.MarshalIndent(events, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return errors.WithStack(err)
+	}
+	return errors.New("incorrect format. Must be one of table|json")
+}
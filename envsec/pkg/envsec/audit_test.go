@@ -0,0 +1,145 @@
+package envsec
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingSink keeps the audit events it receives, for tests.
+type recordingSink struct {
+	events []AuditEvent
+}
+
+func (r *recordingSink) WriteAuditEvent(_ context.Context, event AuditEvent) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestAuditEvents(t *testing.T) {
+	sink := &recordingSink{}
+	e := &Envsec{
+		Auditor: sink,
+		EnvID:   testEnvID("dev"),
+		Stderr:  io.Discard,
+		Store:   newMemStore(map[string]map[string]string{"prod": {"A": "secret"}}),
+	}
+	ctx := context.Background()
+	if err := e.SetMap(ctx, map[string]string{"B": "2", "A": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.DeleteAll(ctx, "B"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Sync(ctx, testEnvID("prod"), testEnvID("dev"), SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		action AuditAction
+		env    string
+		names  []string
+	}{
+		{AuditSet, "dev", []string{"A", "B"}},
+		{AuditDelete, "dev", []string{"B"}},
+		{AuditSync, "prod", []string{"A"}},
+		{AuditSet, "dev", []string{"A"}},
+	}
+	if len(sink.events) != len(expected) {
+		t.Fatalf("Expected %d events, but got %v", len(expected), sink.events)
+	}
+	for i, event := range sink.events {
+		if event.Action != expected[i].action ||
+			event.Environment != expected[i].env ||
+			event.ProjectID != "proj" ||
+			!reflect.DeepEqual(event.Names, expected[i].names) {
+			t.Errorf("Expected %v, but got %v", expected[i], event)
+		}
+	}
+
+	data, err := json.Marshal(sink.events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{`"1"`, `"2"`, "secret"} {
+		if strings.Contains(string(data), value) {
+			t.Errorf("Expected audit events not to contain value %s, but got %s", value, data)
+		}
+	}
+}
+
+func TestReadAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink := &FileAuditSink{Path: path}
+	now := time.Now().UTC().Truncate(time.Second)
+	events := []AuditEvent{
+		{Time: now.Add(-48 * time.Hour), Action: AuditSet, User: "u1", Environment: "dev", Names: []string{"A"}},
+		{Time: now, Action: AuditExec, User: "u2", Email: "u2@example.com", Environment: "prod", Names: []string{"A", "B"}},
+		{Time: now, Action: AuditDownload, User: "u1", Environment: "dev", Names: []string{"B"}},
+	}
+	for _, event := range events {
+		if err := sink.WriteAuditEvent(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   AuditFilter
+		expected []AuditEvent
+	}{
+		{"all", AuditFilter{}, events},
+		{"since", AuditFilter{Since: now.Add(-time.Hour)}, events[1:]},
+		{"email", AuditFilter{User: "u2@example.com"}, events[1:2]},
+		{"name", AuditFilter{Name: "A", Environment: "PROD"}, events[1:2]},
+		{"action", AuditFilter{Action: AuditSet}, events[:1]},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ReadAuditLog(path, test.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("Expected %v, but got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestWebhookAuditSink(t *testing.T) {
+	var received AuditEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Error(err)
+		}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	sink, err := NewAuditSink(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := AuditEvent{Action: AuditList, User: "u", Names: []string{"A"}}
+	if err := sink.WriteAuditEvent(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(received, event) {
+		t.Errorf("Expected %v, but got %v", event, received)
+	}
+
+	sink, _ = NewAuditSink(server.URL + "/fail")
+	if err := sink.WriteAuditEvent(context.Background(), event); err == nil {
+		t.Error("Expected an error from a failing webhook, but got nil")
+	}
+}
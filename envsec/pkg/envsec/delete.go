@@ -11,6 +11,7 @@ func (e *Envsec) DeleteAll(ctx context.Context, envNames ...string) error {
 	if err := e.Store.DeleteAll(ctx, e.EnvID, envNames); err != nil {
 		return err
 	}
+	e.Audit(ctx, AuditDelete, e.EnvID, envNames)
 	return tux.WriteHeader(e.Stderr,
 		"[DONE] Deleted environment %s %v in environment: %s\n",
 		tux.Plural(envNames, "variable", "variables"),
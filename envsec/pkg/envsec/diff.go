@@ -0,0 +1,241 @@
+package envsec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/envsec/internal/tux"
+)
+
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Changed ChangeKind = "changed"
+)
+
+// EnvVarDiff is a difference in a variable between two environments.
+type EnvVarDiff struct {
+	Name string
+	Kind ChangeKind
+	// From is the value in the environment being compared from, empty if the
+	// variable was added.
+	From string
+	// To is the value in the environment being compared to, empty if the
+	// variable was removed.
+	To string
+	// Config is set if the variable is config wherever it's set, in which case
+	// its values aren't masked.
+	Config bool
+}
+
+// DiffEnvVars returns what changes from one set of variables to another,
+// sorted by name. Variables with the same value in both are left out.
+func DiffEnvVars(from []EnvVar, to []EnvVar) []EnvVarDiff {
+	fromVars := lo.KeyBy(from, func(v EnvVar) string { return v.Name })
+	toVars := lo.KeyBy(to, func(v EnvVar) string { return v.Name })
+
+	diffs := []EnvVarDiff{}
+	for name, fromVar := range fromVars {
+		toVar, ok := toVars[name]
+		if !ok {
+			diffs = append(diffs, EnvVarDiff{
+				Name:   name,
+				Kind:   Removed,
+				From:   fromVar.Value,
+				Config: !fromVar.IsSecret(),
+			})
+		} else if toVar.Value != fromVar.Value {
+			diffs = append(diffs, EnvVarDiff{
+				Name:   name,
+				Kind:   Changed,
+				From:   fromVar.Value,
+				To:     toVar.Value,
+				Config: !fromVar.IsSecret() && !toVar.IsSecret(),
+			})
+		}
+	}
+	for name, toVar := range toVars {
+		if _, ok := fromVars[name]; !ok {
+			diffs = append(diffs, EnvVarDiff{
+				Name:   name,
+				Kind:   Added,
+				To:     toVar.Value,
+				Config: !toVar.IsSecret(),
+			})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	return diffs
+}
+
+// Diff compares the variables of two environments.
+func (e *Envsec) Diff(ctx context.Context, from EnvID, to EnvID) ([]EnvVarDiff, error) {
+	fromVars, toVars, err := e.listBoth(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return DiffEnvVars(fromVars, toVars), nil
+}
+
+func (e *Envsec) listBoth(ctx context.Context, from EnvID, to EnvID) ([]EnvVar, []EnvVar, error) {
+	fromVars, err := e.Store.List(ctx, from)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	toVars, err := e.Store.List(ctx, to)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return fromVars, toVars, nil
+}
+
+// SyncOptions configure how Sync copies variables between environments.
+type SyncOptions struct {
+	// Only limits the sync to these names. All variables are synced if empty.
+	Only []string
+	// Prune deletes variables that are set in the target environment but not
+	// in the source one.
+	Prune bool
+	// DryRun reports what would change without changing anything.
+	DryRun bool
+}
+
+// Sync copies variables from one environment to another, so that the target
+// has the same values as the source. It returns the changes it made to the
+// target, or would make in a dry run: From is the target's previous value and
+// To its new one. Stores that keep metadata copy it along with the values.
+// Copying values is audited as a read of the source.
+func (e *Envsec) Sync(ctx context.Context, from EnvID, to EnvID, opts SyncOptions) ([]EnvVarDiff, error) {
+	if err := ensureValidNames(opts.Only); err != nil {
+		return nil, err
+	}
+	sourceVars, targetVars, err := e.listBoth(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	sourceByName := lo.KeyBy(sourceVars, func(v EnvVar) string { return v.Name })
+	// Compare from the target's point of view: what it gains is "added".
+	diffs := DiffEnvVars(targetVars, sourceVars)
+
+	changes := []EnvVarDiff{}
+	toSet := []EnvVar{}
+	toDelete := []string{}
+	for _, diff := range diffs {
+		if len(opts.Only) > 0 && !lo.Contains(opts.Only, diff.Name) {
+			continue
+		}
+		if diff.Kind == Removed {
+			if !opts.Prune {
+				continue
+			}
+			toDelete = append(toDelete, diff.Name)
+		} else {
+			toSet = append(toSet, sourceByName[diff.Name])
+		}
+		changes = append(changes, diff)
+	}
+
+	if opts.DryRun {
+		return changes, nil
+	}
+	if len(toSet) > 0 {
+		if err := e.setAllIn(ctx, to, toSet); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		names := lo.Map(toSet, func(v EnvVar, _ int) string { return v.Name })
+		e.Audit(ctx, AuditSync, from, names)
+		e.Audit(ctx, AuditSet, to, names)
+	}
+	if len(toDelete) > 0 {
+		if err := e.Store.DeleteAll(ctx, to, toDelete); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		e.Audit(ctx, AuditDelete, to, toDelete)
+	}
+	return changes, nil
+}
+
+// setAllIn sets variables in an environment, with their metadata if the store
+// keeps metadata.
+func (e *Envsec) setAllIn(ctx context.Context, envID EnvID, vars []EnvVar) error {
+	if metadataStore, ok := e.Store.(MetadataStore); ok {
+		return metadataStore.SetAllWithMetadata(ctx, envID, vars)
+	}
+	values := lo.SliceToMap(vars, func(v EnvVar) (string, string) { return v.Name, v.Value })
+	return e.Store.SetAll(ctx, envID, values)
+}
+
+// PrintDiff prints the differences between two environments, masking the
+// values of secrets unless expose is set.
+func PrintDiff(
+	w io.Writer,
+	from string,
+	to string,
+	diffs []EnvVarDiff,
+	expose bool,
+	format string,
+) error {
+	masked := make([]EnvVarDiff, 0, len(diffs))
+	for _, diff := range diffs {
+		if !expose && !diff.Config {
+			if diff.Kind != Added {
+				diff.From = "*****"
+			}
+			if diff.Kind != Removed {
+				diff.To = "*****"
+			}
+		}
+		masked = append(masked, diff)
+	}
+
+	switch format {
+	case "table":
+		return printDiffTable(w, from, to, masked)
+	case "json":
+		data, err := json.MarshalIndent(masked, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return errors.WithStack(err)
+	default:
+		return errors.New("incorrect format. Must be one of table|json")
+	}
+}
+
+func printDiffTable(w io.Writer, from string, to string, diffs []EnvVarDiff) error {
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	err := tux.WriteHeader(w, "Changes from %s to %s\n", from, to)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(diffs) == 0 {
+		_, err := fmt.Fprintf(w, "No differences between %s and %s.\n\n", from, to)
+		return errors.WithStack(err)
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Name", "Change", from, to})
+	for _, diff := range diffs {
+		fromValue, toValue := diff.From, diff.To
+		switch diff.Kind {
+		case Added:
+			fromValue = "-"
+		case Removed:
+			toValue = "-"
+		}
+		table.Append([]string{diff.Name, string(diff.Kind), fromValue, toValue})
+	}
+	table.Render()
+	_, err = fmt.Fprintln(w)
+	return errors.WithStack(err)
+}
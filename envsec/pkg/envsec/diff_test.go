@@ -0,0 +1,115 @@
+package envsec
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffEnvVars(t *testing.T) {
+	from := []EnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}, {Name: "C", Value: "3"}}
+	to := []EnvVar{{Name: "B", Value: "2"}, {Name: "C", Value: "4"}, {Name: "D", Value: "5"}}
+
+	expected := []EnvVarDiff{
+		{Name: "A", Kind: Removed, From: "1"},
+		{Name: "C", Kind: Changed, From: "3", To: "4"},
+		{Name: "D", Kind: Added, To: "5"},
+	}
+	if diffs := DiffEnvVars(from, to); !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("Expected %v, but got %v", expected, diffs)
+	}
+}
+
+func TestSync(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     SyncOptions
+		expected map[string]string
+	}{
+		{
+			name:     "all",
+			expected: map[string]string{"A": "1", "B": "2", "PROD_ONLY": "x"},
+		},
+		{
+			name:     "prune",
+			opts:     SyncOptions{Prune: true},
+			expected: map[string]string{"A": "1", "B": "2"},
+		},
+		{
+			name:     "only",
+			opts:     SyncOptions{Only: []string{"B", "PROD_ONLY"}, Prune: true},
+			expected: map[string]string{"B": "2"},
+		},
+		{
+			name:     "dry run",
+			opts:     SyncOptions{Prune: true, DryRun: true},
+			expected: map[string]string{"B": "old", "PROD_ONLY": "x"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newMemStore(map[string]map[string]string{
+				"dev":  {"A": "1", "B": "2"},
+				"prod": {"B": "old", "PROD_ONLY": "x"},
+			})
+			e := &Envsec{Store: store}
+			_, err := e.Sync(context.Background(), testEnvID("dev"), testEnvID("prod"), test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if prod := store.envs[testEnvID("prod")]; !reflect.DeepEqual(prod, test.expected) {
+				t.Errorf("Expected %v, but got %v", test.expected, prod)
+			}
+		})
+	}
+}
+
+func TestSyncMetadata(t *testing.T) {
+	store := newMemStore(map[string]map[string]string{})
+	ctx := context.Background()
+	metadata := EnvVarMetadata{Owner: "team-a", Sensitivity: SensitivityConfig}
+	err := store.SetAllWithMetadata(ctx, testEnvID("dev"), []EnvVar{
+		{Name: "A", Value: "1", EnvVarMetadata: metadata},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &Envsec{Store: store}
+	if _, err := e.Sync(ctx, testEnvID("dev"), testEnvID("prod"), SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []EnvVar{{Name: "A", Value: "1", EnvVarMetadata: metadata}}
+	if vars, _ := store.List(ctx, testEnvID("prod")); !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, but got %v", expected, vars)
+	}
+}
+
+func TestPrintDiffMasksSecrets(t *testing.T) {
+	config := EnvVarMetadata{Sensitivity: SensitivityConfig}
+	from := []EnvVar{
+		{Name: "HOST", Value: "dev.example.com", EnvVarMetadata: config},
+		{Name: "PASSWORD", Value: "hunter2"},
+	}
+	to := []EnvVar{
+		{Name: "HOST", Value: "prod.example.com", EnvVarMetadata: config},
+		{Name: "PASSWORD", Value: "hunter3"},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintDiff(&buf, "dev", "prod", DiffEnvVars(from, to), false, "table"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{"dev.example.com", "prod.example.com", "*****"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected %v in %v", s, out)
+		}
+	}
+	if strings.Contains(out, "hunter") {
+		t.Errorf("Expected secrets to be masked, but got %v", out)
+	}
+}
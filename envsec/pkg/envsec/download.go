@@ -10,20 +10,63 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"go.jetpack.io/envsec/internal/tux"
 )
 
+// DownloadOptions configure how variables are downloaded.
+type DownloadOptions struct {
+	// Format of the file, dotenv, json, k8s-secret, shell, fish, docker or
+	// systemd. If empty, we default to dotenv format unless the path ends in
+	// .json
+	Format string
+	// Raw downloads values as stored, without expanding references to other
+	// variables.
+	Raw bool
+	// Sensitivity, if set, downloads only the variables of that sensitivity,
+	// so that config can be written to a file that's committed while secrets
+	// are kept out of it.
+	Sensitivity Sensitivity
+	// Kubernetes names the objects of the k8s-secret format.
+	Kubernetes KubernetesOptions
+	// WithMetadata writes variables that have metadata as objects with their
+	// value and metadata, in the json format. Otherwise the file maps names to
+	// values.
+	WithMetadata bool
+}
+
 // Download downloads the environment variables for the environment specified.
-// If format is empty, we default to dotenv format unless path ends in .json
+// If format is empty, we default to dotenv format unless path ends in .json.
+// If path is -, the variables are written to e.Stdout.
 func (e *Envsec) Download(ctx context.Context, path, format string) error {
+	return e.DownloadWithOptions(ctx, path, DownloadOptions{Format: format})
+}
+
+// DownloadWithOptions is like Download, with more options.
+func (e *Envsec) DownloadWithOptions(ctx context.Context, path string, opts DownloadOptions) error {
+	format := opts.Format
 	if err := ValidateFormat(format); err != nil {
 		return err
 	}
+	if format == k8sSecretFormat {
+		if err := opts.Kubernetes.validate(); err != nil {
+			return err
+		}
+	}
 
-	envVars, err := e.List(ctx)
+	list := e.ListResolved
+	if opts.Raw {
+		list = e.List
+	}
+	envVars, err := list(ctx)
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	if opts.Sensitivity != "" {
+		envVars = lo.Filter(envVars, func(v EnvVar, _ int) bool {
+			return v.IsSecret() == (opts.Sensitivity == SensitivitySecret)
+		})
+	}
 
 	if len(envVars) == 0 {
 		err = tux.WriteHeader(e.Stderr,
@@ -33,7 +76,7 @@ func (e *Envsec) Download(ctx context.Context, path, format string) error {
 		return errors.WithStack(err)
 	}
 
-	if !filepath.IsAbs(path) {
+	if !filepath.IsAbs(path) && path != "-" {
 		path = filepath.Join(e.WorkingDir, path)
 	}
 
@@ -41,15 +84,24 @@ func (e *Envsec) Download(ctx context.Context, path, format string) error {
 	for _, envVar := range envVars {
 		envVarMap[envVar.Name] = envVar.Value
 	}
+	envVarNames := lo.Keys(envVarMap)
 
 	if format == "" && filepath.Ext(path) == ".json" {
 		format = "json"
 	}
+	if opts.WithMetadata && format != "json" {
+		return errors.New("metadata can only be downloaded in the json format")
+	}
 
 	var contents []byte
-	if format == "json" {
-		contents, err = encodeToJSON(envVarMap)
-	} else {
+	switch format {
+	case "json":
+		contents, err = encodeToJSON(envVars, opts.WithMetadata)
+	case k8sSecretFormat:
+		contents, err = encodeToKubernetes(envVars, opts.Kubernetes)
+	case shellFormat, fishFormat, dockerFormat, systemdFormat:
+		contents, err = encodeLines(envVars, format)
+	default:
 		contents, err = encodeToDotEnv(envVarMap)
 	}
 
@@ -57,10 +109,24 @@ func (e *Envsec) Download(ctx context.Context, path, format string) error {
 		return errors.WithStack(err)
 	}
 
+	if path == "-" {
+		// Nothing else is printed, so that the output can be eval'd.
+		stdout := e.Stdout
+		if stdout == nil {
+			stdout = os.Stdout
+		}
+		_, err = stdout.Write(contents)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		e.Audit(ctx, AuditDownload, e.EnvID, envVarNames)
+		return nil
+	}
 	err = os.WriteFile(path, contents, 0o644)
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	e.Audit(ctx, AuditDownload, e.EnvID, envVarNames)
 	err = tux.WriteHeader(e.Stderr,
 		"[DONE] Downloaded environment variables to %q for environment: %s\n",
 		path,
@@ -73,7 +139,15 @@ func (e *Envsec) Download(ctx context.Context, path, format string) error {
 	return nil
 }
 
-func encodeToJSON(m map[string]string) ([]byte, error) {
+func encodeToJSON(envVars []EnvVar, withMetadata bool) ([]byte, error) {
+	m := map[string]any{}
+	for _, envVar := range envVars {
+		if withMetadata {
+			m[envVar.Name] = newJSONEnvVar(envVar)
+		} else {
+			m[envVar.Name] = envVar.Value
+		}
+	}
 	b := new(bytes.Buffer)
 	encoder := json.NewEncoder(b)
 	encoder.SetEscapeHTML(false)
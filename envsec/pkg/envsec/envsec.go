@@ -8,13 +8,22 @@ import (
 )
 
 type Envsec struct {
-	APIHost    string
+	APIHost string
+	// Auditor, if set, receives an event for every read or change of
+	// variables.
+	Auditor    AuditSink
 	Auth       AuthConfig
 	EnvID      EnvID
 	IsDev      bool
 	Stderr     io.Writer
 	Store      Store
 	WorkingDir string
+	// Stdout receives variables downloaded to -. If nil, os.Stdout is used.
+	Stdout io.Writer
+
+	// token is the session returned by InitForUser, which identifies the
+	// user in audit events.
+	token *session.Token
 }
 
 type AuthConfig struct {
@@ -26,5 +35,7 @@ type AuthConfig struct {
 }
 
 func (e *Envsec) InitForUser(ctx context.Context) (*session.Token, error) {
-	return e.Store.InitForUser(ctx, e)
+	tok, err := e.Store.InitForUser(ctx, e)
+	e.token = tok
+	return tok, err
 }
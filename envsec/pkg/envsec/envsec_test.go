@@ -0,0 +1,124 @@
+package envsec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/samber/lo"
+
+	"go.jetpack.io/pkg/auth/session"
+)
+
+// memStore is a Store that keeps variables and their metadata in memory, for
+// tests.
+type memStore struct {
+	envs     map[EnvID]map[string]string
+	metadata map[EnvID]map[string]EnvVarMetadata
+}
+
+var (
+	_ Store         = (*memStore)(nil)
+	_ MetadataStore = (*memStore)(nil)
+)
+
+// valuesOnlyStore hides the MetadataStore methods of a store.
+type valuesOnlyStore struct {
+	Store
+}
+
+func newMemStore(envs map[string]map[string]string) *memStore {
+	m := &memStore{
+		envs:     map[EnvID]map[string]string{},
+		metadata: map[EnvID]map[string]EnvVarMetadata{},
+	}
+	for envName, vars := range envs {
+		_ = m.SetAll(context.Background(), testEnvID(envName), vars)
+	}
+	return m
+}
+
+// expandReferences gives e a project config that turns on the expansion of
+// references.
+func expandReferences(t *testing.T, e *Envsec) {
+	t.Helper()
+	e.WorkingDir = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(e.WorkingDir, dirName), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	config := []byte(`{"expand_references": true}`)
+	if err := os.WriteFile(e.configPath(e.WorkingDir), config, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testEnvID(envName string) EnvID {
+	return EnvID{ProjectID: "proj", OrgID: "org", EnvName: envName}
+}
+
+func (m *memStore) InitForUser(context.Context, *Envsec) (*session.Token, error) {
+	return nil, nil
+}
+
+func (m *memStore) List(_ context.Context, envID EnvID) ([]EnvVar, error) {
+	result := []EnvVar{}
+	for name, value := range m.envs[envID] {
+		result = append(result, EnvVar{Name: name, Value: value, EnvVarMetadata: m.metadata[envID][name]})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (m *memStore) Set(ctx context.Context, envID EnvID, name string, value string) error {
+	return m.SetAll(ctx, envID, map[string]string{name: value})
+}
+
+func (m *memStore) SetAll(_ context.Context, envID EnvID, values map[string]string) error {
+	if m.envs[envID] == nil {
+		m.envs[envID] = map[string]string{}
+	}
+	for name, value := range values {
+		m.envs[envID][name] = value
+	}
+	return nil
+}
+
+func (m *memStore) SetAllWithMetadata(ctx context.Context, envID EnvID, vars []EnvVar) error {
+	if m.metadata[envID] == nil {
+		m.metadata[envID] = map[string]EnvVarMetadata{}
+	}
+	for _, v := range vars {
+		m.metadata[envID][v.Name] = v.EnvVarMetadata
+	}
+	return m.SetAll(ctx, envID, lo.SliceToMap(vars, func(v EnvVar) (string, string) {
+		return v.Name, v.Value
+	}))
+}
+
+func (m *memStore) Get(_ context.Context, envID EnvID, name string) (string, error) {
+	return m.envs[envID][name], nil
+}
+
+func (m *memStore) GetAll(_ context.Context, envID EnvID, names []string) ([]EnvVar, error) {
+	result := []EnvVar{}
+	for _, name := range names {
+		if value, ok := m.envs[envID][name]; ok {
+			result = append(result, EnvVar{Name: name, Value: value, EnvVarMetadata: m.metadata[envID][name]})
+		}
+	}
+	return result, nil
+}
+
+func (m *memStore) Delete(ctx context.Context, envID EnvID, name string) error {
+	return m.DeleteAll(ctx, envID, []string{name})
+}
+
+func (m *memStore) DeleteAll(_ context.Context, envID EnvID, names []string) error {
+	for _, name := range names {
+		delete(m.envs[envID], name)
+		delete(m.metadata[envID], name)
+	}
+	return nil
+}
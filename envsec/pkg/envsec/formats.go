@@ -0,0 +1,80 @@
+package envsec
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Formats that variables can be downloaded in, but not uploaded from, since
+// they're meant to be read by other programs.
+const (
+	// shellFormat is export NAME='value' lines, for eval in POSIX shells.
+	shellFormat = "shell"
+	// fishFormat is set -gx NAME 'value' lines, for eval in fish.
+	fishFormat = "fish"
+	// dockerFormat is a file for docker run --env-file.
+	dockerFormat = "docker"
+	// systemdFormat is a file for the EnvironmentFile= of systemd units.
+	systemdFormat = "systemd"
+)
+
+// lineEncoders encode a variable as a line of a format. They return an error
+// for values that the format can't hold.
+var lineEncoders = map[string]func(EnvVar) (string, error){
+	shellFormat:   shellLine,
+	fishFormat:    fishLine,
+	dockerFormat:  dockerLine,
+	systemdFormat: systemdLine,
+}
+
+// encodeLines encodes variables one per line, sorted by name, in one of the
+// formats of lineEncoders.
+func encodeLines(envVars []EnvVar, format string) ([]byte, error) {
+	encode := lineEncoders[format]
+	sorted := append([]EnvVar{}, envVars...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var b strings.Builder
+	for _, v := range sorted {
+		line, err := encode(v)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return []byte(b.String()), nil
+}
+
+// shellLine quotes the value in single quotes, in which POSIX shells take
+// everything literally, newlines included. Single quotes in the value close
+// the quotes, add an escaped quote and open them again.
+func shellLine(v EnvVar) (string, error) {
+	return "export " + v.Name + "='" + strings.ReplaceAll(v.Value, "'", `'\''`) + "'", nil
+}
+
+// fishLine quotes the value in single quotes, in which fish only treats \'
+// and \\ as escapes.
+func fishLine(v EnvVar) (string, error) {
+	value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v.Value)
+	return "set -gx " + v.Name + " '" + value + "'", nil
+}
+
+// dockerLine writes the value as is: Docker takes everything after the = of
+// each line literally, quotes included. So values can't have newlines.
+func dockerLine(v EnvVar) (string, error) {
+	if strings.ContainsAny(v.Value, "\r\n") {
+		return "", errors.Errorf(
+			"the value of %s has a newline, which Docker env files can't hold", v.Name)
+	}
+	return v.Name + "=" + v.Value, nil
+}
+
+// systemdLine quotes the value in double quotes, in which systemd keeps
+// newlines and takes \", \\, \$ and \` as escapes, like POSIX shells do.
+func systemdLine(v EnvVar) (string, error) {
+	value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`").Replace(v.Value)
+	return v.Name + `="` + value + `"`, nil
+}
@@ -0,0 +1,159 @@
+package envsec
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// trickyVars have values that need quoting or escaping in some format.
+var trickyVars = []EnvVar{
+	{Name: "BACKSLASH", Value: `C:\path\n`},
+	{Name: "DOLLAR", Value: "$HOME ${HOME} `id` $(id)"},
+	{Name: "DOUBLE", Value: `say "hi"`},
+	{Name: "EMPTY", Value: ""},
+	{Name: "NEWLINE", Value: "line 1\nline 2\n"},
+	{Name: "SINGLE", Value: "it's 'quoted'"},
+	{Name: "SPACES", Value: "  padded  "},
+}
+
+func TestEncodeLines(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: "shell",
+			expected: `export BACKSLASH='C:\path\n'
+export DOLLAR='$HOME ${HOME} ` + "`id`" + ` $(id)'
+export DOUBLE='say "hi"'
+export EMPTY=''
+export NEWLINE='line 1
+line 2
+'
+export SINGLE='it'\''s '\''quoted'\'''
+export SPACES='  padded  '
+`,
+		},
+		{
+			format: "fish",
+			expected: `set -gx BACKSLASH 'C:\\path\\n'
+set -gx DOLLAR '$HOME ${HOME} ` + "`id`" + ` $(id)'
+set -gx DOUBLE 'say "hi"'
+set -gx EMPTY ''
+set -gx NEWLINE 'line 1
+line 2
+'
+set -gx SINGLE 'it\'s \'quoted\''
+set -gx SPACES '  padded  '
+`,
+		},
+		{
+			format: "systemd",
+			expected: `BACKSLASH="C:\\path\\n"
+DOLLAR="\$HOME \${HOME} ` + "\\`id\\`" + ` \$(id)"
+DOUBLE="say \"hi\""
+EMPTY=""
+NEWLINE="line 1
+line 2
+"
+SINGLE="it's 'quoted'"
+SPACES="  padded  "
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			data, err := encodeLines(trickyVars, test.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.expected {
+				t.Errorf("Expected %v, but got %v", test.expected, string(data))
+			}
+		})
+	}
+}
+
+// TestEncodeLinesShell checks that shells read back the values exactly. The
+// systemd format is also valid shell, and is parsed the same way by systemd.
+func TestEncodeLinesShell(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh isn't installed")
+	}
+	for _, format := range []string{"shell", "systemd"} {
+		t.Run(format, func(t *testing.T) {
+			data, err := encodeLines(trickyVars, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range trickyVars {
+				// Command substitution would strip trailing newlines, so
+				// the value is delimited.
+				script := "set -a\n" + string(data) + "printf '[%s]' \"$" + v.Name + "\""
+				output, err := exec.Command("sh", "-c", script).Output()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expected := "[" + v.Value + "]"; string(output) != expected {
+					t.Errorf("Expected %q, but got %q", expected, string(output))
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeLinesDocker(t *testing.T) {
+	// Docker takes values literally, quotes included.
+	vars := []EnvVar{
+		{Name: "DOUBLE", Value: `say "hi"`},
+		{Name: "SINGLE", Value: "it's"},
+		{Name: "SPACES", Value: "  padded  "},
+	}
+	data, err := encodeLines(vars, "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "DOUBLE=say \"hi\"\nSINGLE=it's\nSPACES=  padded  \n"
+	if string(data) != expected {
+		t.Errorf("Expected %q, but got %q", expected, string(data))
+	}
+
+	_, err = encodeLines(trickyVars, "docker")
+	if err == nil || !strings.Contains(err.Error(), "NEWLINE") {
+		t.Errorf("Expected an error about NEWLINE, but got %v", err)
+	}
+}
+
+func TestDownloadStdout(t *testing.T) {
+	store := newMemStore(map[string]map[string]string{"dev": {"A": "it's"}})
+	var stdout, stderr bytes.Buffer
+	e := &Envsec{
+		EnvID:      testEnvID("dev"),
+		Stderr:     &stderr,
+		Stdout:     &stdout,
+		Store:      store,
+		WorkingDir: t.TempDir(),
+	}
+	if err := e.DownloadWithOptions(context.Background(), "-", DownloadOptions{Format: "shell"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "export A='it'\\''s'\n"
+	if stdout.String() != expected {
+		t.Errorf("Expected %q, but got %q", expected, stdout.String())
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("Expected nothing on stderr, but got %q", stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(e.WorkingDir, "-")); err == nil {
+		t.Error("Expected no file named -")
+	}
+
+	if err := e.Upload(context.Background(), []string{"-"}, "shell"); err == nil {
+		t.Error("Expected uploading shell files to fail, but got nil")
+	}
+}
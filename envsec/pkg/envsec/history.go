@@ -0,0 +1,149 @@
+package envsec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"go.jetpack.io/envsec/internal/tux"
+)
+
+// ErrHistoryNotSupported is returned for stores that don't keep previous
+// versions of variables.
+var ErrHistoryNotSupported = errors.New("this store doesn't keep the history of variables")
+
+// Versioned is an optional capability of stores that keep the previous
+// values of variables.
+type Versioned interface {
+	// History returns the versions of a variable, oldest first. It returns no
+	// versions for a variable that was never set.
+	History(ctx context.Context, envID EnvID, name string) ([]EnvVarVersion, error)
+}
+
+// EnvVarVersion is a version of an environment variable.
+type EnvVarVersion struct {
+	// Version identifies the version within the variable's history. Versions
+	// increase, but aren't necessarily consecutive.
+	Version int
+	Value   string
+	// Deleted is set for versions that deleted the variable.
+	Deleted bool `json:",omitempty"`
+	// Timestamp is when the version was created, if the store records it.
+	Timestamp time.Time
+	// Author is who created the version, if the store records it.
+	Author string `json:",omitempty"`
+}
+
+func (e *Envsec) History(ctx context.Context, name string) ([]EnvVarVersion, error) {
+	versioned, ok := e.Store.(Versioned)
+	if !ok {
+		return nil, ErrHistoryNotSupported
+	}
+	return versioned.History(ctx, e.EnvID, name)
+}
+
+// Rollback restores a variable to the value it had at a previous version.
+// The restored value becomes a new version, so rollbacks can be undone.
+func (e *Envsec) Rollback(ctx context.Context, name string, version int) error {
+	versions, err := e.History(ctx, name)
+	if err != nil {
+		return err
+	}
+	var target *EnvVarVersion
+	for i := range versions {
+		if versions[i].Version == version {
+			target = &versions[i]
+		}
+	}
+	if target == nil {
+		return errors.Errorf("%s has no version %d in environment %s", name, version, e.EnvID.EnvName)
+	}
+
+	action := AuditSet
+	if target.Deleted {
+		action = AuditDelete
+		err = e.Store.Delete(ctx, e.EnvID, name)
+	} else {
+		err = e.Store.Set(ctx, e.EnvID, name, target.Value)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	e.Audit(ctx, action, e.EnvID, []string{name})
+	return tux.WriteHeader(e.Stderr,
+		"[DONE] Rolled back environment variable '%s' to version %d in environment: %s\n",
+		name,
+		version,
+		strings.ToLower(e.EnvID.EnvName),
+	)
+}
+
+// PrintHistory prints the versions of a variable, masking their values
+// unless expose is set.
+func PrintHistory(
+	w io.Writer,
+	envID EnvID,
+	name string,
+	versions []EnvVarVersion,
+	expose bool,
+	format string,
+) error {
+	masked := make([]EnvVarVersion, 0, len(versions))
+	for _, v := range versions {
+		if !expose && !v.Deleted {
+			v.Value = "*****"
+		}
+		masked = append(masked, v)
+	}
+
+	switch format {
+	case "table":
+		return printHistoryTable(w, envID, name, masked)
+	case "json":
+		data, err := json.MarshalIndent(masked, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return errors.WithStack(err)
+	default:
+		return errors.New("incorrect format. Must be one of table|json")
+	}
+}
+
+func printHistoryTable(w io.Writer, envID EnvID, name string, versions []EnvVarVersion) error {
+	err := tux.WriteHeader(w,
+		"History of %s in environment: %s\n", name, strings.ToLower(envID.EnvName))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(versions) == 0 {
+		_, err := fmt.Fprintf(w, "%s has never been set.\n\n", name)
+		return errors.WithStack(err)
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Version", "Date", "Author", "Value"})
+	for _, v := range versions {
+		date, author, value := "-", "-", v.Value
+		if !v.Timestamp.IsZero() {
+			date = v.Timestamp.Local().Format(time.DateTime)
+		}
+		if v.Author != "" {
+			author = v.Author
+		}
+		if v.Deleted {
+			value = "(deleted)"
+		}
+		table.Append([]string{strconv.Itoa(v.Version), date, author, value})
+	}
+	table.Render()
+	_, err = fmt.Fprintln(w)
+	return errors.WithStack(err)
+}
@@ -28,6 +28,19 @@ const (
 type projectConfig struct {
 	ProjectID id.ProjectID `json:"project_id"`
 	OrgID     id.OrgID     `json:"org_id"`
+	// Store selects where the project's variables are kept. Empty means the
+	// Jetify API. See envcli's --store flag for the accepted values.
+	Store string `json:"store,omitempty"`
+	// AuditSinks are URLs of sinks that receive audit events, in addition to
+	// the local audit log. See NewAuditSink. envcli only uses the file://
+	// sinks, since webhooks here would be chosen by whoever wrote the repo.
+	AuditSinks []string `json:"audit_sinks,omitempty"`
+	// Rotation maps variable names to their rotation policies.
+	Rotation map[string]RotationPolicy `json:"rotation,omitempty"`
+	// ExpandReferences turns on the expansion of references such as
+	// ${DB_HOST} in values. It's off by default, since values stored before
+	// references existed may contain ${ or $${ meant literally.
+	ExpandReferences bool `json:"expand_references,omitempty"`
 }
 
 func (e *Envsec) NewProject(ctx context.Context, force bool) error {
@@ -95,7 +108,12 @@ func (e *Envsec) configName() string {
 }
 
 func (e *Envsec) saveConfig(projectID id.ProjectID, orgID id.OrgID) error {
-	cfg := projectConfig{ProjectID: projectID, OrgID: orgID}
+	cfg := projectConfig{}
+	// Keep the settings of a project that's being re-initialized.
+	if existing, err := e.ProjectConfig(); err == nil {
+		cfg = *existing
+	}
+	cfg.ProjectID, cfg.OrgID = projectID, orgID
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return err
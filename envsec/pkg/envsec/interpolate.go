@@ -0,0 +1,214 @@
+package envsec
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+// referenceRegex matches references to other variables in values:
+//
+//	${NAME}               NAME in the same environment
+//	${prod:NAME}          NAME in the prod environment of the same project
+//	${project/prod:NAME}  NAME in the prod environment of another project
+//
+// and the escape $${, which stands for a literal ${. Anything else, such as
+// $NAME or a ${ that isn't closed, is left as is, so that values that happen
+// to contain dollar signs don't need escaping.
+var referenceRegex = regexp.MustCompile(
+	`\$\$\{|\$\{(?:(?:([A-Za-z0-9_.-]+)/)?([A-Za-z0-9_-]+):)?([A-Za-z_][A-Za-z0-9_]*)\}`,
+)
+
+// reference identifies a variable that a value refers to.
+type reference struct {
+	envID EnvID
+	name  string
+}
+
+func (r reference) String() string {
+	return r.envID.ProjectID + "/" + r.envID.EnvName + ":" + r.name
+}
+
+// resolver expands the references in values, loading the variables of other
+// environments as needed.
+type resolver struct {
+	ctx   context.Context
+	store Store
+	// origin is the environment whose variables are being resolved.
+	origin EnvID
+	// envs caches the variables of each environment.
+	envs map[EnvID]map[string]EnvVar
+	// resolved caches the expanded value of each reference.
+	resolved map[reference]string
+	// secret records which expanded values are secrets, or contain one.
+	secret map[reference]bool
+	// read records the names read from environments other than origin.
+	read map[EnvID]map[string]bool
+	// stack holds the references being resolved, to detect cycles.
+	stack []reference
+}
+
+// Resolve expands the references to other variables, such as ${DB_HOST}, in
+// the values of vars, which are variables of e.EnvID as returned by List.
+// Config variables whose values refer to secrets are returned as secrets,
+// since their expanded values contain the secrets. Reads of other
+// environments are audited once all the values are expanded.
+func (e *Envsec) Resolve(ctx context.Context, vars []EnvVar) ([]EnvVar, error) {
+	r := &resolver{
+		ctx:      ctx,
+		store:    e.Store,
+		origin:   e.EnvID,
+		envs:     map[EnvID]map[string]EnvVar{},
+		resolved: map[reference]string{},
+		secret:   map[reference]bool{},
+		read:     map[EnvID]map[string]bool{},
+	}
+	own := map[string]EnvVar{}
+	for _, v := range vars {
+		own[v.Name] = v
+	}
+	r.envs[e.EnvID] = own
+
+	result := make([]EnvVar, 0, len(vars))
+	for _, v := range vars {
+		ref := reference{envID: e.EnvID, name: v.Name}
+		value, err := r.resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		v.Value = value
+		if !v.IsSecret() && r.secret[ref] {
+			v.Sensitivity = SensitivitySecret
+		}
+		result = append(result, v)
+	}
+	for envID, names := range r.read {
+		e.Audit(ctx, AuditReference, envID, lo.Keys(names))
+	}
+	return result, nil
+}
+
+// ListResolved lists the variables of the environment with their references
+// expanded, if the project turned expansion on with expand_references.
+// Otherwise values are listed as stored, like List.
+func (e *Envsec) ListResolved(ctx context.Context) ([]EnvVar, error) {
+	vars, err := e.List(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if config, err := e.ProjectConfig(); err != nil || !config.ExpandReferences {
+		return vars, nil
+	}
+	return e.Resolve(ctx, vars)
+}
+
+func (r *resolver) resolve(ref reference) (string, error) {
+	if value, ok := r.resolved[ref]; ok {
+		return value, nil
+	}
+	for i, s := range r.stack {
+		if s == ref {
+			cycle := []string{}
+			for _, s := range append(r.stack[i:], ref) {
+				cycle = append(cycle, r.display(s))
+			}
+			return "", errors.Errorf("references form a cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	vars, err := r.env(ref.envID)
+	if err != nil {
+		return "", err
+	}
+	v, ok := vars[ref.name]
+	if !ok {
+		if len(r.stack) == 0 {
+			return "", errors.Errorf("%s isn't set", r.display(ref))
+		}
+		return "", errors.Errorf(
+			"%s refers to %s, which isn't set",
+			r.display(r.stack[len(r.stack)-1]),
+			r.display(ref),
+		)
+	}
+
+	if ref.envID != r.origin {
+		if r.read[ref.envID] == nil {
+			r.read[ref.envID] = map[string]bool{}
+		}
+		r.read[ref.envID][ref.name] = true
+	}
+
+	r.stack = append(r.stack, ref)
+	value, secret, err := r.expand(ref.envID, v.Value)
+	r.stack = r.stack[:len(r.stack)-1]
+	if err != nil {
+		return "", err
+	}
+	r.resolved[ref] = value
+	r.secret[ref] = secret || v.IsSecret()
+	return value, nil
+}
+
+// expand replaces the references in a value of the environment envID. It
+// also reports whether any of the values it refers to is a secret.
+func (r *resolver) expand(envID EnvID, value string) (string, bool, error) {
+	var out strings.Builder
+	secret := false
+	last := 0
+	for _, m := range referenceRegex.FindAllStringSubmatchIndex(value, -1) {
+		out.WriteString(value[last:m[0]])
+		last = m[1]
+		if value[m[0]:m[1]] == "$${" {
+			out.WriteString("${")
+			continue
+		}
+
+		ref := reference{envID: envID, name: value[m[6]:m[7]]}
+		if m[2] >= 0 {
+			ref.envID.ProjectID = value[m[2]:m[3]]
+		}
+		if m[4] >= 0 {
+			ref.envID.EnvName = value[m[4]:m[5]]
+		}
+		resolved, err := r.resolve(ref)
+		if err != nil {
+			return "", false, err
+		}
+		out.WriteString(resolved)
+		secret = secret || r.secret[ref]
+	}
+	out.WriteString(value[last:])
+	return out.String(), secret, nil
+}
+
+func (r *resolver) env(envID EnvID) (map[string]EnvVar, error) {
+	if vars, ok := r.envs[envID]; ok {
+		return vars, nil
+	}
+	list, err := r.store.List(r.ctx, envID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list variables of environment %s", envID.EnvName)
+	}
+	vars := make(map[string]EnvVar, len(list))
+	for _, v := range list {
+		vars[v.Name] = v
+	}
+	r.envs[envID] = vars
+	return vars, nil
+}
+
+// display formats a reference the way it would be written in the value of a
+// variable of the environment being resolved.
+func (r *resolver) display(ref reference) string {
+	switch {
+	case ref.envID == r.origin:
+		return ref.name
+	case ref.envID.ProjectID == r.origin.ProjectID:
+		return ref.envID.EnvName + ":" + ref.name
+	}
+	return ref.String()
+}
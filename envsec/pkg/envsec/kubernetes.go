@@ -0,0 +1,279 @@
+package envsec
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/envsec/internal/tux"
+	"gopkg.in/yaml.v3"
+)
+
+// k8sSecretFormat is the format of Kubernetes manifests: a Secret with the
+// secrets, and a ConfigMap with the config.
+const k8sSecretFormat = "k8s-secret"
+
+// KubernetesOptions name the Secret and ConfigMap that variables are
+// exported to.
+type KubernetesOptions struct {
+	// Name of the Secret. The ConfigMap is named <Name>-config.
+	Name string
+	// Namespace of both objects. If empty, manifests have no namespace.
+	Namespace string
+}
+
+// ApplyOptions configure how variables are written for kustomize.
+type ApplyOptions struct {
+	KubernetesOptions
+	// Dir is the directory that the manifests are written to.
+	Dir string
+	// Raw applies values as stored, without expanding references to other
+	// variables.
+	Raw bool
+}
+
+// kubernetesObject is a Secret or a ConfigMap.
+type kubernetesObject struct {
+	APIVersion string             `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string             `json:"kind" yaml:"kind"`
+	Metadata   kubernetesMetadata `json:"metadata" yaml:"metadata"`
+	Type       string             `json:"type,omitempty" yaml:"type,omitempty"`
+	Data       map[string]string  `json:"data" yaml:"data"`
+	// StringData is only read, from Secrets that weren't written by envsec.
+	StringData map[string]string `json:"-" yaml:"stringData,omitempty"`
+}
+
+type kubernetesMetadata struct {
+	Name      string            `json:"name" yaml:"name"`
+	Namespace string            `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+// kubernetesNameRegex matches the names that Kubernetes allows for Secrets
+// and ConfigMaps, RFC 1123 subdomains.
+var kubernetesNameRegex = regexp.MustCompile(
+	`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`,
+)
+
+// kubernetesNamespaceRegex matches namespaces, which are RFC 1123 labels.
+var kubernetesNamespaceRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+func (o KubernetesOptions) validate() error {
+	if o.Name == "" {
+		return errors.New("the name of the Kubernetes Secret is required. Use --name")
+	}
+	// The ConfigMap's name is 7 characters longer.
+	if !kubernetesNameRegex.MatchString(o.Name) || len(o.Name) > 253-len("-config") {
+		return errors.Errorf(
+			"invalid Kubernetes name %q. Use lowercase letters, digits, '-' and '.'", o.Name)
+	}
+	if o.Namespace != "" && (!kubernetesNamespaceRegex.MatchString(o.Namespace) || len(o.Namespace) > 63) {
+		return errors.Errorf(
+			"invalid Kubernetes namespace %q. Use lowercase letters, digits and '-'", o.Namespace)
+	}
+	return nil
+}
+
+// kubernetesObjects returns a Secret with the secrets of envVars and a
+// ConfigMap with their config. Both are returned even if they are empty, so
+// that the set of objects doesn't change as variables are classified.
+func kubernetesObjects(envVars []EnvVar, opts KubernetesOptions) []kubernetesObject {
+	labels := map[string]string{"app.kubernetes.io/managed-by": "envsec"}
+	secret := kubernetesObject{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   kubernetesMetadata{Name: opts.Name, Namespace: opts.Namespace, Labels: labels},
+		Type:       "Opaque",
+		Data:       map[string]string{},
+	}
+	configMap := kubernetesObject{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Metadata: kubernetesMetadata{
+			Name:      opts.Name + "-config",
+			Namespace: opts.Namespace,
+			Labels:    labels,
+		},
+		Data: map[string]string{},
+	}
+	for _, v := range envVars {
+		if v.IsSecret() {
+			secret.Data[v.Name] = base64.StdEncoding.EncodeToString([]byte(v.Value))
+		} else {
+			configMap.Data[v.Name] = v.Value
+		}
+	}
+	return []kubernetesObject{secret, configMap}
+}
+
+// encodeToKubernetes encodes variables as a multi-document YAML manifest.
+func encodeToKubernetes(envVars []EnvVar, opts KubernetesOptions) ([]byte, error) {
+	objects := kubernetesObjects(envVars, opts)
+	return encodeYAML(objects[0], objects[1])
+}
+
+// encodeYAML encodes values as YAML documents, indented like kubectl does.
+func encodeYAML(values ...any) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	for _, v := range values {
+		if err := encoder.Encode(v); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return b.Bytes(), nil
+}
+
+// loadFromKubernetes reads the variables of the Secrets and ConfigMaps in a
+// YAML manifest. Other objects are skipped.
+func loadFromKubernetes(path string) (map[string]EnvVar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer file.Close()
+
+	envVars := map[string]EnvVar{}
+	decoder := yaml.NewDecoder(file)
+	for {
+		var obj kubernetesObject
+		err := decoder.Decode(&obj)
+		if errors.Is(err, io.EOF) {
+			return envVars, nil
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse Kubernetes manifest %s", path)
+		}
+
+		switch obj.Kind {
+		case "Secret":
+			for name, encoded := range obj.Data {
+				value, err := base64.StdEncoding.DecodeString(encoded)
+				if err != nil {
+					return nil, errors.Wrapf(
+						err, "invalid base64 value of %s in Secret %s", name, obj.Metadata.Name)
+				}
+				envVars[name] = EnvVar{Name: name, Value: string(value)}
+			}
+			// stringData takes precedence over data, like in Kubernetes.
+			for name, value := range obj.StringData {
+				envVars[name] = EnvVar{Name: name, Value: value}
+			}
+		case "ConfigMap":
+			for name, value := range obj.Data {
+				envVars[name] = EnvVar{
+					Name:           name,
+					Value:          value,
+					EnvVarMetadata: EnvVarMetadata{Sensitivity: SensitivityConfig},
+				}
+			}
+		}
+	}
+}
+
+// ApplyKubernetes exports the variables of the environment to a Secret and a
+// ConfigMap, and writes them to a directory for kustomize.
+func (e *Envsec) ApplyKubernetes(ctx context.Context, opts ApplyOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	if opts.Dir == "" {
+		return errors.New("the directory to write the manifests to is required. Use --dir")
+	}
+	list := e.ListResolved
+	if opts.Raw {
+		list = e.List
+	}
+	envVars, err := list(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := e.writeKustomization(envVars, opts); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(envVars))
+	for _, v := range envVars {
+		names = append(names, v.Name)
+	}
+	e.Audit(ctx, AuditApply, e.EnvID, names)
+	return tux.WriteHeader(e.Stderr,
+		"[DONE] Wrote Kubernetes manifests to %q for environment: %s\n",
+		opts.Dir,
+		strings.ToLower(e.EnvID.EnvName),
+	)
+}
+
+// writeKustomization writes the Secret and the ConfigMap to their own files
+// in opts.Dir, along with a kustomization.yaml that lists them if the
+// directory doesn't have one yet. An existing kustomization.yaml is left as
+// is, with a warning if it doesn't list the files.
+func (e *Envsec) writeKustomization(envVars []EnvVar, opts ApplyOptions) error {
+	dir := opts.Dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(e.WorkingDir, dir)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errors.WithStack(err)
+	}
+
+	files := []string{"secret.yaml", "configmap.yaml"}
+	for i, obj := range kubernetesObjects(envVars, opts.KubernetesOptions) {
+		data, err := encodeYAML(obj)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		// The Secret shouldn't be readable by others, even base64-encoded.
+		if err := os.WriteFile(filepath.Join(dir, files[i]), data, 0o600); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	kustomization := filepath.Join(dir, "kustomization.yaml")
+	if existing, err := os.ReadFile(kustomization); err == nil {
+		e.warnUnlisted(kustomization, existing, files)
+		return nil
+	}
+	data, err := encodeYAML(map[string]any{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"resources":  files,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(kustomization, data, 0o644))
+}
+
+// warnUnlisted warns about the files that an existing kustomization doesn't
+// list in its resources, since kustomize would leave them out.
+func (e *Envsec) warnUnlisted(path string, kustomization []byte, files []string) {
+	var parsed struct {
+		Resources []string `yaml:"resources"`
+	}
+	// An invalid kustomization lists nothing, so every file is warned about.
+	_ = yaml.Unmarshal(kustomization, &parsed)
+	unlisted := []string{}
+	for _, file := range files {
+		if !lo.Contains(parsed.Resources, file) {
+			unlisted = append(unlisted, file)
+		}
+	}
+	if len(unlisted) > 0 {
+		fmt.Fprintf(e.Stderr,
+			"Warning: %s doesn't list %s in its resources, so kustomize won't apply them. Add them to it\n",
+			path, strings.Join(unlisted, " and "),
+		)
+	}
+}
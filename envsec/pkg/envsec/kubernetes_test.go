@@ -0,0 +1,153 @@
+package envsec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newKubernetesTestEnvsec(t *testing.T) (*Envsec, *memStore) {
+	t.Helper()
+	store := newMemStore(map[string]map[string]string{
+		"dev": {"LOG_LEVEL": "debug", "TOKEN": "hunter2", "MULTILINE": "a\nb"},
+	})
+	store.metadata[testEnvID("dev")] = map[string]EnvVarMetadata{
+		"LOG_LEVEL": {Sensitivity: SensitivityConfig},
+	}
+	e := &Envsec{EnvID: testEnvID("dev"), Stderr: io.Discard, Store: store, WorkingDir: t.TempDir()}
+	return e, store
+}
+
+func TestDownloadKubernetes(t *testing.T) {
+	e, _ := newKubernetesTestEnvsec(t)
+	ctx := context.Background()
+
+	opts := DownloadOptions{Format: "k8s-secret"}
+	if err := e.DownloadWithOptions(ctx, "secrets.yaml", opts); err == nil {
+		t.Error("Expected an error without a name, but got nil")
+	}
+	opts.Kubernetes = KubernetesOptions{Name: "App_Secrets"}
+	if err := e.DownloadWithOptions(ctx, "secrets.yaml", opts); err == nil {
+		t.Error("Expected an error for an invalid name, but got nil")
+	}
+
+	opts.Kubernetes = KubernetesOptions{Name: "app-secrets", Namespace: "prod"}
+	if err := e.DownloadWithOptions(ctx, "secrets.yaml", opts); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(e.WorkingDir, "secrets.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `apiVersion: v1
+kind: Secret
+metadata:
+  name: app-secrets
+  namespace: prod
+  labels:
+    app.kubernetes.io/managed-by: envsec
+type: Opaque
+data:
+  MULTILINE: YQpi
+  TOKEN: aHVudGVyMg==
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-secrets-config
+  namespace: prod
+  labels:
+    app.kubernetes.io/managed-by: envsec
+data:
+  LOG_LEVEL: debug
+`
+	if string(data) != expected {
+		t.Errorf("Expected %v, but got %v", expected, string(data))
+	}
+
+	// The manifest can be uploaded again.
+	e.EnvID = testEnvID("prod")
+	if err := e.Upload(ctx, []string{"secrets.yaml"}, "k8s-secret"); err != nil {
+		t.Fatal(err)
+	}
+	vars, err := e.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedVars := []EnvVar{
+		{Name: "LOG_LEVEL", Value: "debug", EnvVarMetadata: EnvVarMetadata{Sensitivity: SensitivityConfig}},
+		{Name: "MULTILINE", Value: "a\nb"},
+		{Name: "TOKEN", Value: "hunter2"},
+	}
+	if !reflect.DeepEqual(vars, expectedVars) {
+		t.Errorf("Expected %v, but got %v", expectedVars, vars)
+	}
+}
+
+func TestApplyKubernetesDir(t *testing.T) {
+	e, _ := newKubernetesTestEnvsec(t)
+	ctx := context.Background()
+	dir := filepath.Join(e.WorkingDir, "k8s")
+
+	opts := ApplyOptions{KubernetesOptions: KubernetesOptions{Name: "app"}, Dir: "k8s"}
+	if err := e.ApplyKubernetes(ctx, opts); err != nil {
+		t.Fatal(err)
+	}
+	kustomization, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - secret.yaml
+  - configmap.yaml
+`
+	if string(kustomization) != expected {
+		t.Errorf("Expected %v, but got %v", expected, string(kustomization))
+	}
+	vars, err := loadFromKubernetes(filepath.Join(dir, "configmap.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value := vars["LOG_LEVEL"].Value; value != "debug" {
+		t.Errorf("Expected debug, but got %v", value)
+	}
+
+	// Applying again keeps the kustomization.yaml, which lists the files.
+	var stderr bytes.Buffer
+	e.Stderr = &stderr
+	if err := e.ApplyKubernetes(ctx, opts); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stderr.String(), "Warning") {
+		t.Errorf("Expected no warning, but got %v", stderr.String())
+	}
+
+	// An existing kustomization.yaml is left alone, with a warning about the
+	// files it doesn't list.
+	custom := "resources:\n  - deployment.yaml\n  - configmap.yaml\n"
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(custom), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stderr.Reset()
+	if err := e.ApplyKubernetes(ctx, opts); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "kustomization.yaml")); string(data) != custom {
+		t.Errorf("Expected %v, but got %v", custom, string(data))
+	}
+	if !strings.Contains(stderr.String(), "doesn't list secret.yaml in its resources") {
+		t.Errorf("Expected a warning about secret.yaml, but got %v", stderr.String())
+	}
+
+	opts.Dir = ""
+	if err := e.ApplyKubernetes(ctx, opts); err == nil {
+		t.Error("Expected an error without a directory, but got nil")
+	}
+}
@@ -6,14 +6,23 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"go.jetpack.io/envsec/internal/tux"
 )
 
+// List lists the variables of the environment. Names starting with JETPACK_
+// are reserved for envsec, such as MetadataVarName, and are left out, since
+// stores may keep them as ordinary variables.
 func (e *Envsec) List(ctx context.Context) ([]EnvVar, error) {
-	return e.Store.List(ctx, e.EnvID)
+	vars, err := e.Store.List(ctx, e.EnvID)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Reject(vars, func(v EnvVar, _ int) bool { return isReservedName(v.Name) }), nil
 }
 
 func PrintEnvVar(
@@ -23,43 +32,98 @@ func PrintEnvVar(
 	expose bool,
 	format string,
 ) error {
+	return PrintEnvVarWithOptions(w, envID, envVars, PrintOptions{Expose: expose, Format: format})
+}
+
+// PrintOptions configure how variables are printed.
+type PrintOptions struct {
+	// Expose prints the values of secrets instead of masking them. The values
+	// of config variables are always printed.
+	Expose bool
+	// Format is one of table, dotenv, json, shell, fish, docker or systemd.
+	Format string
+	// Stale holds the age of variables that are older than their rotation
+	// policy allows, as returned by StaleVars. If it's non-nil, tables get a
+	// Stale column.
+	Stale map[string]time.Duration
+}
+
+// PrintEnvVarWithOptions is like PrintEnvVar, with more options.
+func PrintEnvVarWithOptions(w io.Writer, envID EnvID, envVars []EnvVar, opts PrintOptions) error {
 	envVarsMaskedValue := []EnvVar{}
-	// Masking envVar values if printValue flag isn't set
+	// Masking the values of secrets if printValue flag isn't set
 	for _, envVar := range envVars {
-		valueToPrint := "*****"
-		if expose {
-			valueToPrint = envVar.Value
+		if !opts.Expose && envVar.IsSecret() {
+			envVar.Value = "*****"
 		}
-		envVarsMaskedValue = append(envVarsMaskedValue, EnvVar{
-			Name:  envVar.Name,
-			Value: valueToPrint,
-		})
-
+		envVarsMaskedValue = append(envVarsMaskedValue, envVar)
 	}
 
-	switch format {
+	switch opts.Format {
 	case "table":
-		return printTableFormat(w, envID, envVarsMaskedValue)
+		return printTableFormat(w, envID, envVarsMaskedValue, opts.Stale)
 	case "dotenv":
 		return printDotenvFormat(envVarsMaskedValue)
 	case "json":
 		return printJSONFormat(envVarsMaskedValue)
+	case shellFormat, fishFormat, dockerFormat, systemdFormat:
+		data, err := encodeLines(envVarsMaskedValue, opts.Format)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return errors.WithStack(err)
 	default:
-		return errors.New("incorrect format. Must be one of table|dotenv|json")
+		return errors.New("incorrect format. Must be one of table|dotenv|json|shell|fish|docker|systemd")
 	}
 }
 
-func printTableFormat(w io.Writer, envID EnvID, envVars []EnvVar) error {
+func printTableFormat(w io.Writer, envID EnvID, envVars []EnvVar, stale map[string]time.Duration) error {
 	err := tux.WriteHeader(w, "Environment: %s\n", strings.ToLower(envID.EnvName))
 	if err != nil {
 		return errors.WithStack(err)
 	}
 	table := tablewriter.NewWriter(w)
-	table.SetHeader([]string{"Name", "Value"})
-	tableValues := [][]string{}
-	for _, envVar := range envVars {
-		tableValues = append(tableValues, []string{envVar.Name /*name*/, envVar.Value})
+	columns := []tableColumn{
+		{header: "Name", value: func(v EnvVar) string { return v.Name }, always: true},
+		{header: "Value", value: func(v EnvVar) string { return v.Value }, always: true},
+		{header: "Description", value: func(v EnvVar) string { return v.Description }},
+		{header: "Owner", value: func(v EnvVar) string { return v.Owner }},
+		{header: "Expires", value: formatExpires},
+		{header: "Sensitivity", value: func(v EnvVar) string { return string(v.Sensitivity) }},
+		{header: "Tags", value: func(v EnvVar) string { return FormatTags(v.Tags) }},
+	}
+	if stale != nil {
+		columns = append(columns, tableColumn{
+			header: "Stale",
+			value: func(v EnvVar) string {
+				if age, ok := stale[v.Name]; ok {
+					return "yes (" + formatAge(age) + " old)"
+				}
+				return ""
+			},
+			always: true,
+		})
+	}
+
+	header := []string{}
+	tableValues := make([][]string, len(envVars))
+	for _, column := range columns {
+		values := make([]string, len(envVars))
+		empty := true
+		for i, envVar := range envVars {
+			values[i] = column.value(envVar)
+			empty = empty && values[i] == ""
+		}
+		if empty && !column.always {
+			continue
+		}
+		header = append(header, column.header)
+		for i := range envVars {
+			tableValues[i] = append(tableValues[i], values[i])
+		}
 	}
+	table.SetHeader(header)
 	table.AppendBulk(tableValues)
 
 	if len(tableValues) == 0 {
@@ -74,6 +138,26 @@ func printTableFormat(w io.Writer, envID EnvID, envVars []EnvVar) error {
 	return nil
 }
 
+// tableColumn is a column of the table of variables. Columns that aren't
+// always shown are left out when no variable has a value for them.
+type tableColumn struct {
+	header string
+	value  func(EnvVar) string
+	always bool
+}
+
+// formatExpires formats when a variable expires, flagging expired ones.
+func formatExpires(v EnvVar) string {
+	if v.Expires == nil {
+		return ""
+	}
+	date := v.Expires.Format(time.DateOnly)
+	if v.Expires.Before(time.Now()) {
+		return date + " (expired)"
+	}
+	return date
+}
+
 func printDotenvFormat(envVars []EnvVar) error {
 	keyValsToPrint := ""
 	for _, envVar := range envVars {
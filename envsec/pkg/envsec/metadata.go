@@ -0,0 +1,223 @@
+package envsec
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrMetadataNotSupported is returned when setting metadata in stores that
+// only keep values.
+var ErrMetadataNotSupported = errors.New("this store doesn't keep metadata of variables")
+
+// Sensitivity says whether a variable is a secret.
+type Sensitivity string
+
+const (
+	// SensitivitySecret is for values that must be kept secret. It's the
+	// default.
+	SensitivitySecret Sensitivity = "secret"
+	// SensitivityConfig is for plain configuration that doesn't need to be
+	// kept secret, such as LOG_LEVEL.
+	SensitivityConfig Sensitivity = "config"
+)
+
+// ParseSensitivity parses secret or config.
+func ParseSensitivity(s string) (Sensitivity, error) {
+	switch Sensitivity(s) {
+	case SensitivitySecret, SensitivityConfig:
+		return Sensitivity(s), nil
+	}
+	return "", errors.Errorf("invalid sensitivity %q. Must be one of secret|config", s)
+}
+
+// EnvVarMetadata describes an environment variable.
+type EnvVarMetadata struct {
+	Description string     `json:",omitempty"`
+	Owner       string     `json:",omitempty"`
+	Expires     *time.Time `json:",omitempty"`
+	// Sensitivity is empty for variables that were never classified, which
+	// are treated as secrets.
+	Sensitivity Sensitivity       `json:",omitempty"`
+	Tags        map[string]string `json:",omitempty"`
+}
+
+// IsZero reports whether m has no metadata.
+func (m EnvVarMetadata) IsZero() bool {
+	return m.Description == "" &&
+		m.Owner == "" &&
+		m.Expires == nil &&
+		m.Sensitivity == "" &&
+		len(m.Tags) == 0
+}
+
+// IsSecret reports whether the value must be kept secret, which is the case
+// for all variables but those classified as config.
+func (m EnvVarMetadata) IsSecret() bool {
+	return m.Sensitivity != SensitivityConfig
+}
+
+// Merge returns m with the fields that are set in update replaced, and the
+// tags of update added.
+func (m EnvVarMetadata) Merge(update EnvVarMetadata) EnvVarMetadata {
+	if update.Description != "" {
+		m.Description = update.Description
+	}
+	if update.Owner != "" {
+		m.Owner = update.Owner
+	}
+	if update.Expires != nil {
+		m.Expires = update.Expires
+	}
+	if update.Sensitivity != "" {
+		m.Sensitivity = update.Sensitivity
+	}
+	if len(update.Tags) > 0 {
+		tags := make(map[string]string, len(m.Tags)+len(update.Tags))
+		for k, v := range m.Tags {
+			tags[k] = v
+		}
+		for k, v := range update.Tags {
+			tags[k] = v
+		}
+		m.Tags = tags
+	}
+	return m
+}
+
+// FormatTags formats tags as a sorted, comma-separated list of key=value
+// pairs, leaving out the = for tags without a value.
+func FormatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if v == "" {
+			pairs = append(pairs, k)
+		} else {
+			pairs = append(pairs, k+"="+v)
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// MetadataStore is an optional capability of stores that keep metadata with
+// variables. Their List and GetAll return the metadata, and their SetAll
+// keeps the metadata of the variables it sets.
+type MetadataStore interface {
+	// SetAllWithMetadata sets the values of variables and replaces their
+	// metadata.
+	SetAllWithMetadata(ctx context.Context, envID EnvID, vars []EnvVar) error
+}
+
+// MetadataVarName is the name of the variable in which stores that can only
+// keep values keep the metadata of the others. Names starting with JETPACK_
+// are reserved, so it can't clash with user variables.
+const MetadataVarName = "JETPACK_ENVSEC_METADATA"
+
+// EncodeMetadata encodes metadata by variable name, for MetadataVarName.
+func EncodeMetadata(metadata map[string]EnvVarMetadata) (string, error) {
+	data, err := json.Marshal(metadata)
+	return string(data), errors.WithStack(err)
+}
+
+// DecodeMetadata decodes the value of MetadataVarName.
+func DecodeMetadata(value string) (map[string]EnvVarMetadata, error) {
+	metadata := map[string]EnvVarMetadata{}
+	if value == "" {
+		return metadata, nil
+	}
+	if err := json.Unmarshal([]byte(value), &metadata); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the metadata of variables")
+	}
+	return metadata, nil
+}
+
+// jsonEnvVar is how variables with metadata are written in JSON files. The
+// files map names to values, and variables without metadata have plain
+// string values, so that files without metadata stay flat.
+type jsonEnvVar struct {
+	Value       string            `json:"value"`
+	Description string            `json:"description,omitempty"`
+	Owner       string            `json:"owner,omitempty"`
+	Expires     *time.Time        `json:"expires,omitempty"`
+	Sensitivity Sensitivity       `json:"sensitivity,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+func (v *jsonEnvVar) UnmarshalJSON(data []byte) error {
+	// Plain strings are values without metadata.
+	if err := json.Unmarshal(data, &v.Value); err == nil {
+		return nil
+	}
+	type plain jsonEnvVar
+	return json.Unmarshal(data, (*plain)(v))
+}
+
+func newJSONEnvVar(v EnvVar) any {
+	if v.EnvVarMetadata.IsZero() {
+		return v.Value
+	}
+	return jsonEnvVar{
+		Value:       v.Value,
+		Description: v.Description,
+		Owner:       v.Owner,
+		Expires:     v.Expires,
+		Sensitivity: v.Sensitivity,
+		Tags:        v.Tags,
+	}
+}
+
+func (v jsonEnvVar) envVar(name string) EnvVar {
+	return EnvVar{
+		Name:  name,
+		Value: v.Value,
+		EnvVarMetadata: EnvVarMetadata{
+			Description: v.Description,
+			Owner:       v.Owner,
+			Expires:     v.Expires,
+			Sensitivity: v.Sensitivity,
+			Tags:        v.Tags,
+		},
+	}
+}
+
+// SetWithMetadata sets variables like SetMap, and merges update into their
+// metadata.
+func (e *Envsec) SetWithMetadata(
+	ctx context.Context,
+	envMap map[string]string,
+	update EnvVarMetadata,
+) error {
+	if update.IsZero() {
+		return e.SetMap(ctx, envMap)
+	}
+	names := make([]string, 0, len(envMap))
+	for name := range envMap {
+		names = append(names, name)
+	}
+	if err := ensureValidNames(names); err != nil {
+		return errors.WithStack(err)
+	}
+	existing, err := e.Store.GetAll(ctx, e.EnvID, names)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	metadata := map[string]EnvVarMetadata{}
+	for _, v := range existing {
+		metadata[v.Name] = v.EnvVarMetadata
+	}
+
+	vars := make([]EnvVar, 0, len(envMap))
+	for name, value := range envMap {
+		vars = append(vars, EnvVar{
+			Name:           name,
+			Value:          value,
+			EnvVarMetadata: metadata[name].Merge(update),
+		})
+	}
+	return e.setVars(ctx, vars)
+}
@@ -0,0 +1,237 @@
+package envsec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetWithMetadata(t *testing.T) {
+	store := newMemStore(map[string]map[string]string{})
+	e := &Envsec{EnvID: testEnvID("dev"), Stderr: io.Discard, Store: store}
+	ctx := context.Background()
+
+	err := e.SetWithMetadata(ctx, map[string]string{"A": "1"}, EnvVarMetadata{
+		Owner: "team-a",
+		Tags:  map[string]string{"tier": "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the given metadata is replaced, and tags are added.
+	err = e.SetWithMetadata(ctx, map[string]string{"A": "2"}, EnvVarMetadata{
+		Description: "the A",
+		Tags:        map[string]string{"pci": ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Setting only the value keeps the metadata.
+	if err := e.Set(ctx, "A", "3"); err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := e.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []EnvVar{{
+		Name:  "A",
+		Value: "3",
+		EnvVarMetadata: EnvVarMetadata{
+			Description: "the A",
+			Owner:       "team-a",
+			Tags:        map[string]string{"tier": "1", "pci": ""},
+		},
+	}}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, but got %v", expected, vars)
+	}
+
+	e.Store = valuesOnlyStore{store}
+	err = e.SetWithMetadata(ctx, map[string]string{"A": "4"}, EnvVarMetadata{Owner: "team-b"})
+	if err != ErrMetadataNotSupported {
+		t.Errorf("Expected %v, but got %v", ErrMetadataNotSupported, err)
+	}
+}
+
+func TestListHidesReservedNames(t *testing.T) {
+	// Stores without metadata support keep MetadataVarName, written by stores
+	// that have it, as an ordinary variable.
+	store := newMemStore(map[string]map[string]string{
+		"dev": {"A": "1", MetadataVarName: `{"A":{"Owner":"team-a"}}`, "jetpack_other": "x"},
+	})
+	e := &Envsec{EnvID: testEnvID("dev"), Stderr: io.Discard, Store: store}
+
+	vars, err := e.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []EnvVar{{Name: "A", Value: "1"}}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, but got %v", expected, vars)
+	}
+}
+
+func TestJSONMetadataRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	expires := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	store := newMemStore(map[string]map[string]string{})
+	store.metadata[testEnvID("dev")] = map[string]EnvVarMetadata{
+		"A": {Owner: "team-a", Expires: &expires, Sensitivity: SensitivityConfig},
+	}
+	_ = store.SetAll(context.Background(), testEnvID("dev"), map[string]string{"A": "1", "B": "2"})
+	e := &Envsec{EnvID: testEnvID("dev"), Stderr: io.Discard, Store: store, WorkingDir: dir}
+	ctx := context.Background()
+
+	// By default, files map names to values.
+	if err := e.Download(ctx, "env.json", ""); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "env.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedJSON := "{\n  \"A\": \"1\",\n  \"B\": \"2\"\n}\n"
+	if string(data) != expectedJSON {
+		t.Errorf("Expected %v, but got %v", expectedJSON, string(data))
+	}
+
+	err = e.DownloadWithOptions(ctx, "env.json", DownloadOptions{WithMetadata: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "env.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Variables without metadata stay flat.
+	expectedJSON = `{
+  "A": {
+    "value": "1",
+    "owner": "team-a",
+    "expires": "2030-01-02T00:00:00Z",
+    "sensitivity": "config"
+  },
+  "B": "2"
+}
+`
+	if string(data) != expectedJSON {
+		t.Errorf("Expected %v, but got %v", expectedJSON, string(data))
+	}
+	err = e.DownloadWithOptions(ctx, ".env", DownloadOptions{WithMetadata: true})
+	if err == nil {
+		t.Error("Expected an error downloading metadata to a dotenv file, but got nil")
+	}
+
+	e.EnvID = testEnvID("prod")
+	if err := e.Upload(ctx, []string{"env.json"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	vars, err := e.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []EnvVar{
+		{
+			Name:  "A",
+			Value: "1",
+			EnvVarMetadata: EnvVarMetadata{
+				Owner:       "team-a",
+				Expires:     &expires,
+				Sensitivity: SensitivityConfig,
+			},
+		},
+		{Name: "B", Value: "2"},
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, but got %v", expected, vars)
+	}
+
+	// Stores that don't keep metadata still get the values.
+	var stderr bytes.Buffer
+	e.EnvID = testEnvID("staging")
+	e.Store = valuesOnlyStore{store}
+	e.Stderr = &stderr
+	if err := e.Upload(ctx, []string{"env.json"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), "doesn't keep metadata") {
+		t.Errorf("Expected a warning, but got %q", stderr.String())
+	}
+	if value := store.envs[testEnvID("staging")]["A"]; value != "1" {
+		t.Errorf("Expected 1, but got %v", value)
+	}
+}
+
+func TestPrintMetadataColumns(t *testing.T) {
+	vars := []EnvVar{
+		{Name: "A", Value: "1", EnvVarMetadata: EnvVarMetadata{Owner: "team-a"}},
+		{Name: "B", Value: "2"},
+	}
+	var out bytes.Buffer
+	err := PrintEnvVarWithOptions(&out, testEnvID("dev"), vars, PrintOptions{Format: "table"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "OWNER") || !strings.Contains(out.String(), "team-a") {
+		t.Errorf("Expected an owner column, but got %v", out.String())
+	}
+	// Columns without values are left out.
+	if strings.Contains(out.String(), "DESCRIPTION") {
+		t.Errorf("Expected no description column, but got %v", out.String())
+	}
+}
+
+func TestPrintConfigUnmasked(t *testing.T) {
+	vars := []EnvVar{
+		{Name: "LOG_LEVEL", Value: "debug", EnvVarMetadata: EnvVarMetadata{Sensitivity: SensitivityConfig}},
+		{Name: "TOKEN", Value: "hunter2"},
+	}
+	var out bytes.Buffer
+	err := PrintEnvVarWithOptions(&out, testEnvID("dev"), vars, PrintOptions{Format: "table"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "debug") {
+		t.Errorf("Expected config to be shown, but got %v", out.String())
+	}
+	if strings.Contains(out.String(), "hunter2") {
+		t.Errorf("Expected secrets to be masked, but got %v", out.String())
+	}
+}
+
+func TestDownloadSensitivity(t *testing.T) {
+	dir := t.TempDir()
+	store := newMemStore(map[string]map[string]string{
+		"dev": {"LOG_LEVEL": "debug", "TOKEN": "hunter2"},
+	})
+	store.metadata[testEnvID("dev")] = map[string]EnvVarMetadata{
+		"LOG_LEVEL": {Sensitivity: SensitivityConfig},
+	}
+	e := &Envsec{EnvID: testEnvID("dev"), Stderr: io.Discard, Store: store, WorkingDir: dir}
+
+	for sensitivity, expected := range map[Sensitivity]string{
+		SensitivityConfig: `LOG_LEVEL="debug"`,
+		SensitivitySecret: `TOKEN="hunter2"`,
+	} {
+		path := string(sensitivity) + ".env"
+		opts := DownloadOptions{Format: "dotenv", Sensitivity: sensitivity}
+		if err := e.DownloadWithOptions(context.Background(), path, opts); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected {
+			t.Errorf("Expected %q, but got %q", expected, string(data))
+		}
+	}
+}
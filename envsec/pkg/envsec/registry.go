@@ -0,0 +1,76 @@
+package envsec
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// StoreFactory creates a store from its URL, such as ssm://us-east-1/prefix.
+// The URL's scheme selects the factory, and the rest is up to the factory to
+// interpret.
+type StoreFactory func(u *url.URL) (Store, error)
+
+var (
+	storesMu sync.RWMutex
+	stores   = map[string]StoreFactory{}
+)
+
+// RegisterStore makes a store available under a URL scheme, so that it can be
+// selected with --store or in a project's config. It's meant to be called
+// from init functions, and panics if the scheme is already registered.
+func RegisterStore(scheme string, factory StoreFactory) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	if factory == nil {
+		panic("envsec: RegisterStore factory is nil")
+	}
+	if _, dup := stores[scheme]; dup {
+		panic(fmt.Sprintf("envsec: RegisterStore called twice for scheme %q", scheme))
+	}
+	stores[scheme] = factory
+}
+
+// StoreSchemes returns the registered URL schemes, sorted.
+func StoreSchemes() []string {
+	storesMu.RLock()
+	defer storesMu.RUnlock()
+	schemes := make([]string, 0, len(stores))
+	for scheme := range stores {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// NewStore creates the store for a URL using the factory registered for its
+// scheme. A bare scheme, such as "file", is short for "file://".
+func NewStore(storeURL string) (Store, error) {
+	if !strings.Contains(storeURL, ":") {
+		storeURL += "://"
+	}
+	u, err := url.Parse(storeURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid store URL %q", storeURL)
+	}
+
+	storesMu.RLock()
+	factory, ok := stores[u.Scheme]
+	storesMu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf(
+			"unknown store %q. The scheme must be one of: %s",
+			storeURL,
+			strings.Join(StoreSchemes(), ", "),
+		)
+	}
+	store, err := factory(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid store URL %q", storeURL)
+	}
+	return store, nil
+}
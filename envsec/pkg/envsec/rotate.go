@@ -0,0 +1,259 @@
+package envsec
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/envsec/internal/tux"
+)
+
+// Timestamped is an optional capability of stores that record when
+// variables were last changed. It's needed to find stale secrets.
+type Timestamped interface {
+	// LastModified returns when each variable of the environment was last
+	// changed. Variables whose time isn't known are left out.
+	LastModified(ctx context.Context, envID EnvID) (map[string]time.Time, error)
+}
+
+// RotationPolicy says how often a variable should be rotated and how.
+type RotationPolicy struct {
+	// MaxAge is how long a value may be used before it's stale, such as 720h
+	// or 30d. Variables without a maximum age are never stale.
+	MaxAge string `json:"max_age,omitempty"`
+	// Generate is a shell command that prints the new value. If empty, a
+	// random value of Length alphanumeric characters is generated.
+	Generate string `json:"generate,omitempty"`
+	// Length of random values. Defaults to 32.
+	Length int `json:"length,omitempty"`
+	// Hooks run after the new value is stored, in order.
+	Hooks []RotationHook `json:"hooks,omitempty"`
+}
+
+// RotationHook is run after a variable is rotated. Exactly one of its fields
+// is set.
+type RotationHook struct {
+	// Command is a shell command. It gets the new value in ENVSEC_VALUE.
+	Command string `json:"command,omitempty"`
+	// Webhook is a URL that's sent a POST with the name of the rotated
+	// variable, but not its value.
+	Webhook string `json:"webhook,omitempty"`
+}
+
+const defaultRandomLength = 32
+
+const randomAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// RotationPolicies returns the rotation policies of the project, by
+// variable name.
+func (e *Envsec) RotationPolicies() (map[string]RotationPolicy, error) {
+	config, err := e.ProjectConfig()
+	if errors.Is(err, errProjectNotInitialized) {
+		return map[string]RotationPolicy{}, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for name, policy := range config.Rotation {
+		if _, err := policy.maxAge(); err != nil {
+			return nil, errors.Wrapf(err, "invalid rotation policy for %s", name)
+		}
+	}
+	if config.Rotation == nil {
+		return map[string]RotationPolicy{}, nil
+	}
+	return config.Rotation, nil
+}
+
+// Rotate replaces the value of a variable with a newly generated one, and
+// then runs the policy's hooks.
+func (e *Envsec) Rotate(ctx context.Context, name string, policy RotationPolicy) error {
+	if err := ensureValidNames([]string{name}); err != nil {
+		return err
+	}
+	value, err := e.generate(ctx, name, policy)
+	if err != nil {
+		return errors.Wrapf(err, "failed to generate a new value for %s", name)
+	}
+	if err := e.Store.Set(ctx, e.EnvID, name, value); err != nil {
+		return errors.WithStack(err)
+	}
+	e.Audit(ctx, AuditSet, e.EnvID, []string{name})
+	err = tux.WriteHeader(e.Stderr,
+		"[DONE] Rotated environment variable '%s' in environment: %s\n",
+		name,
+		strings.ToLower(e.EnvID.EnvName),
+	)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for i, hook := range policy.Hooks {
+		if err := e.runHook(ctx, name, value, hook); err != nil {
+			return errors.Wrapf(
+				err, "%s was rotated, but hook %d of %d failed", name, i+1, len(policy.Hooks))
+		}
+	}
+	return nil
+}
+
+// StaleVars returns the age of the variables that are older than their
+// policy's maximum age. It returns nil if the store doesn't record when
+// variables change.
+func (e *Envsec) StaleVars(
+	ctx context.Context,
+	policies map[string]RotationPolicy,
+) (map[string]time.Duration, error) {
+	timestamped, ok := e.Store.(Timestamped)
+	if !ok {
+		return nil, nil
+	}
+	modified, err := timestamped.LastModified(ctx, e.EnvID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	now := time.Now()
+	stale := map[string]time.Duration{}
+	for name, policy := range policies {
+		maxAge, err := policy.maxAge()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid rotation policy for %s", name)
+		}
+		t, ok := modified[name]
+		if maxAge == 0 || !ok {
+			continue
+		}
+		if age := now.Sub(t); age > maxAge {
+			stale[name] = age
+		}
+	}
+	return stale, nil
+}
+
+func (p RotationPolicy) maxAge() (time.Duration, error) {
+	if p.MaxAge == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(p.MaxAge, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, errors.Errorf("invalid max_age %q", p.MaxAge)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(p.MaxAge)
+	if err != nil {
+		return 0, errors.Errorf("invalid max_age %q", p.MaxAge)
+	}
+	return d, nil
+}
+
+func (e *Envsec) generate(ctx context.Context, name string, policy RotationPolicy) (string, error) {
+	if policy.Generate == "" {
+		length := policy.Length
+		if length <= 0 {
+			length = defaultRandomLength
+		}
+		return randomString(length)
+	}
+
+	var stdout bytes.Buffer
+	cmd := e.hookCommand(ctx, policy.Generate, name)
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return "", errors.WithStack(err)
+	}
+	value := strings.TrimRight(stdout.String(), "\r\n")
+	if value == "" {
+		return "", errors.Errorf("%q printed nothing", policy.Generate)
+	}
+	return value, nil
+}
+
+func randomString(length int) (string, error) {
+	alphabetSize := big.NewInt(int64(len(randomAlphabet)))
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		b[i] = randomAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+func (e *Envsec) runHook(ctx context.Context, name string, value string, hook RotationHook) error {
+	switch {
+	case hook.Command != "":
+		cmd := e.hookCommand(ctx, hook.Command, name)
+		cmd.Env = append(cmd.Env, "ENVSEC_VALUE="+value)
+		cmd.Stdout = e.Stderr
+		return errors.Wrapf(cmd.Run(), "%q failed", hook.Command)
+	case hook.Webhook != "":
+		return e.postRotation(ctx, hook.Webhook, name)
+	}
+	return errors.New("hook has neither a command nor a webhook")
+}
+
+// hookCommand returns a shell command that's run during the rotation of a
+// variable, with the variable's name and environment in its environment.
+func (e *Envsec) hookCommand(ctx context.Context, command string, name string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Dir = e.WorkingDir
+	cmd.Env = append(os.Environ(),
+		"ENVSEC_NAME="+name,
+		"ENVSEC_ENVIRONMENT="+strings.ToLower(e.EnvID.EnvName),
+	)
+	cmd.Stderr = e.Stderr
+	return cmd
+}
+
+func (e *Envsec) postRotation(ctx context.Context, url string, name string) error {
+	data, err := json.Marshal(map[string]any{
+		"name":        name,
+		"project_id":  e.EnvID.ProjectID,
+		"environment": strings.ToLower(e.EnvID.EnvName),
+		"rotated_at":  time.Now().UTC(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("webhook %s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// formatAge formats how old a value is, in days once it's two days old.
+func formatAge(age time.Duration) string {
+	if age < time.Hour {
+		return "<1h"
+	}
+	if age >= 48*time.Hour {
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+	return fmt.Sprintf("%dh", int(age.Hours()))
+}
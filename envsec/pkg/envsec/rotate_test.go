@@ -0,0 +1,115 @@
+package envsec
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// timestampedStore is a memStore that reports fixed modification times.
+type timestampedStore struct {
+	*memStore
+	modified map[string]time.Time
+}
+
+func (t *timestampedStore) LastModified(context.Context, EnvID) (map[string]time.Time, error) {
+	return t.modified, nil
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	hookOutput := filepath.Join(dir, "hook")
+	store := newMemStore(map[string]map[string]string{"dev": {"TOKEN": "old", "PASSWORD": "old"}})
+	e := &Envsec{EnvID: testEnvID("dev"), Stderr: io.Discard, Store: store, WorkingDir: dir}
+	ctx := context.Background()
+
+	err := e.Rotate(ctx, "TOKEN", RotationPolicy{Length: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token := store.envs[testEnvID("dev")]["TOKEN"]; len(token) != 12 || token == "old" {
+		t.Errorf("Expected a new random value of length 12, but got %q", token)
+	}
+
+	err = e.Rotate(ctx, "PASSWORD", RotationPolicy{
+		Generate: "echo new-$ENVSEC_NAME-$ENVSEC_ENVIRONMENT",
+		Hooks:    []RotationHook{{Command: "printf %s \"$ENVSEC_VALUE\" > hook"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "new-PASSWORD-dev"
+	if password := store.envs[testEnvID("dev")]["PASSWORD"]; password != expected {
+		t.Errorf("Expected %v, but got %v", expected, password)
+	}
+	if data, err := os.ReadFile(hookOutput); err != nil || string(data) != expected {
+		t.Errorf("Expected hook to get %v, but got %q (%v)", expected, data, err)
+	}
+
+	err = e.Rotate(ctx, "PASSWORD", RotationPolicy{Hooks: []RotationHook{{Command: "exit 1"}}})
+	if err == nil {
+		t.Error("Expected an error from a failing hook, but got nil")
+	}
+}
+
+func TestStaleVars(t *testing.T) {
+	now := time.Now()
+	store := &timestampedStore{
+		memStore: newMemStore(nil),
+		modified: map[string]time.Time{
+			"OLD":     now.Add(-40 * 24 * time.Hour),
+			"NEW":     now.Add(-time.Hour),
+			"NO_MAX":  now.Add(-400 * 24 * time.Hour),
+			"HOURLY":  now.Add(-2 * time.Hour),
+			"UNKNOWN": {},
+		},
+	}
+	e := &Envsec{EnvID: testEnvID("dev"), Store: store}
+	stale, err := e.StaleVars(context.Background(), map[string]RotationPolicy{
+		"OLD":    {MaxAge: "30d"},
+		"NEW":    {MaxAge: "30d"},
+		"NO_MAX": {},
+		"HOURLY": {MaxAge: "1h"},
+		"UNSET":  {MaxAge: "1h"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	staleNames := map[string]bool{}
+	for name := range stale {
+		staleNames[name] = true
+	}
+	expected := map[string]bool{"OLD": true, "HOURLY": true}
+	if !reflect.DeepEqual(staleNames, expected) {
+		t.Errorf("Expected %v, but got %v", expected, staleNames)
+	}
+
+	e.Store = newMemStore(nil)
+	if stale, err := e.StaleVars(context.Background(), nil); err != nil || stale != nil {
+		t.Errorf("Expected no stale variables for a store without times, but got %v (%v)", stale, err)
+	}
+}
+
+func TestRotationPolicyMaxAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":     0,
+		"30d":  30 * 24 * time.Hour,
+		"720h": 720 * time.Hour,
+		"90m":  90 * time.Minute,
+	}
+	for maxAge, expected := range tests {
+		got, err := RotationPolicy{MaxAge: maxAge}.maxAge()
+		if err != nil || !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, but got %v (%v)", expected, got, err)
+		}
+	}
+	for _, maxAge := range []string{"d", "-1d", "soon"} {
+		if _, err := (RotationPolicy{MaxAge: maxAge}).maxAge(); err == nil {
+			t.Errorf("Expected an error for max_age %q, but got nil", maxAge)
+		}
+	}
+}
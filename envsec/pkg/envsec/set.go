@@ -21,11 +21,42 @@ func (e *Envsec) SetMap(ctx context.Context, envMap map[string]string) error {
 		return errors.WithStack(err)
 	}
 
-	err = e.Store.SetAll(ctx, e.EnvID, envMap)
-	if err != nil {
-		return errors.WithStack(err)
+	vars := make([]EnvVar, 0, len(envMap))
+	for name, value := range envMap {
+		vars = append(vars, EnvVar{Name: name, Value: value})
+	}
+	return e.setVars(ctx, vars)
+}
+
+// setVars sets variables. The metadata of variables that have some replaces
+// their stored metadata, and other variables keep theirs.
+func (e *Envsec) setVars(ctx context.Context, vars []EnvVar) error {
+	values := map[string]string{}
+	withMetadata := []EnvVar{}
+	for _, v := range vars {
+		if v.EnvVarMetadata.IsZero() {
+			values[v.Name] = v.Value
+		} else {
+			withMetadata = append(withMetadata, v)
+		}
+	}
+
+	if len(withMetadata) > 0 {
+		metadataStore, ok := e.Store.(MetadataStore)
+		if !ok {
+			return ErrMetadataNotSupported
+		}
+		if err := metadataStore.SetAllWithMetadata(ctx, e.EnvID, withMetadata); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(values) > 0 {
+		if err := e.Store.SetAll(ctx, e.EnvID, values); err != nil {
+			return errors.WithStack(err)
+		}
 	}
-	insertedNames := lo.Keys(envMap)
+	insertedNames := lo.Map(vars, func(v EnvVar, _ int) string { return v.Name })
+	e.Audit(ctx, AuditSet, e.EnvID, insertedNames)
 	return tux.WriteHeader(e.Stderr,
 		"[DONE] Set environment %s %v in environment: %s\n",
 		tux.Plural(insertedNames, "variable", "variables"),
@@ -35,11 +66,21 @@ func (e *Envsec) SetMap(ctx context.Context, envMap map[string]string) error {
 }
 
 func (e *Envsec) SetFromArgs(ctx context.Context, args []string) error {
+	return e.SetFromArgsWithMetadata(ctx, args, EnvVarMetadata{})
+}
+
+// SetFromArgsWithMetadata is like SetFromArgs, and merges metadata into the
+// metadata of the variables.
+func (e *Envsec) SetFromArgsWithMetadata(
+	ctx context.Context,
+	args []string,
+	metadata EnvVarMetadata,
+) error {
 	envMap, err := parseSetArgs(args)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	return e.SetMap(ctx, envMap)
+	return e.SetWithMetadata(ctx, envMap, metadata)
 }
 
 func ValidateSetArgs(args []string) error {
@@ -89,12 +130,16 @@ const nameRegexStr = "^[a-zA-Z_][a-zA-Z0-9_]*"
 
 var nameRegex = regexp.MustCompile(nameRegexStr)
 
+// isReservedName reports whether name starts with JETPACK_, in any case.
+func isReservedName(name string) bool {
+	return strings.HasPrefix(strings.ToLower(name), "jetpack_")
+}
+
 func ensureValidNames(names []string) error {
 	for _, name := range names {
 
 		// Any variation of jetpack_ or JETPACK_ prefix is not allowed
-		lowerName := strings.ToLower(name)
-		if strings.HasPrefix(lowerName, "jetpack_") {
+		if isReservedName(name) {
 			return errors.Errorf(
 				"name %s cannot start with JETPACK_ (or lowercase)",
 				name,
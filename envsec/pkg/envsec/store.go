@@ -51,4 +51,6 @@ type Store interface {
 type EnvVar struct {
 	Name  string
 	Value string
+	// Stores that don't keep metadata leave it empty.
+	EnvVarMetadata
 }
@@ -3,11 +3,13 @@ package envsec
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/joho/godotenv"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"go.jetpack.io/pkg/runx/impl/fileutil"
 )
 
@@ -15,7 +17,7 @@ import (
 // the given paths.
 // If format is empty, we default to dotenv format unless path ends in .json
 func (e *Envsec) Upload(ctx context.Context, paths []string, format string) error {
-	if err := ValidateFormat(format); err != nil {
+	if err := ValidateUploadFormat(format); err != nil {
 		return err
 	}
 
@@ -31,12 +33,18 @@ func (e *Envsec) Upload(ctx context.Context, paths []string, format string) erro
 		filePaths = append(filePaths, path)
 	}
 
-	envMap := map[string]string{}
-	var err error
+	envVars := map[string]EnvVar{}
 	for _, path := range filePaths {
-		var newVars map[string]string
-		if format == "json" || (format == "" && filepath.Ext(path) == ".json") {
-			newVars, err = loadFromJSON([]string{path})
+		if format == k8sSecretFormat {
+			newVars, err := loadFromKubernetes(path)
+			if err != nil {
+				return err
+			}
+			for name, v := range newVars {
+				envVars[name] = v
+			}
+		} else if format == "json" || (format == "" && filepath.Ext(path) == ".json") {
+			newVars, err := loadFromJSON([]string{path})
 			if err != nil {
 				return errors.Wrap(
 					err,
@@ -44,22 +52,41 @@ func (e *Envsec) Upload(ctx context.Context, paths []string, format string) erro
 						"JSON formatted file",
 				)
 			}
+			for name, v := range newVars {
+				envVars[name] = v.envVar(name)
+			}
 		} else {
-			newVars, err = godotenv.Read(path)
+			newVars, err := godotenv.Read(path)
 			if err != nil {
 				return errors.WithStack(err)
 			}
-		}
-		for k, v := range newVars {
-			envMap[k] = v
+			for name, value := range newVars {
+				envVars[name] = EnvVar{Name: name, Value: value}
+			}
 		}
 	}
 
-	return e.SetMap(ctx, envMap)
+	if err := ensureValidNames(lo.Keys(envVars)); err != nil {
+		return errors.WithStack(err)
+	}
+	_, keepsMetadata := e.Store.(MetadataStore)
+	vars := make([]EnvVar, 0, len(envVars))
+	dropped := false
+	for _, v := range envVars {
+		if !keepsMetadata && !v.EnvVarMetadata.IsZero() {
+			v.EnvVarMetadata = EnvVarMetadata{}
+			dropped = true
+		}
+		vars = append(vars, v)
+	}
+	if dropped && e.Stderr != nil {
+		fmt.Fprintln(e.Stderr, "Warning: this store doesn't keep metadata, so only values are uploaded")
+	}
+	return e.setVars(ctx, vars)
 }
 
-func loadFromJSON(filePaths []string) (map[string]string, error) {
-	envMap := map[string]string{}
+func loadFromJSON(filePaths []string) (map[string]jsonEnvVar, error) {
+	envMap := map[string]jsonEnvVar{}
 	for _, filePath := range filePaths {
 		content, err := os.ReadFile(filePath)
 		if err != nil {
@@ -75,9 +102,23 @@ func loadFromJSON(filePaths []string) (map[string]string, error) {
 	return envMap, nil
 }
 
+// ValidateFormat checks that variables can be downloaded in format.
 func ValidateFormat(format string) error {
-	if format != "" && format != "json" && format != "dotenv" {
-		return errors.Errorf("incorrect format. Must be one of json|dotenv")
+	if _, ok := lineEncoders[format]; ok {
+		return nil
+	}
+	if err := ValidateUploadFormat(format); err != nil {
+		return errors.Errorf(
+			"incorrect format. Must be one of json|dotenv|k8s-secret|shell|fish|docker|systemd")
+	}
+	return nil
+}
+
+// ValidateUploadFormat checks that variables can be uploaded from files in
+// format.
+func ValidateUploadFormat(format string) error {
+	if format != "" && format != "json" && format != "dotenv" && format != k8sSecretFormat {
+		return errors.Errorf("incorrect format. Must be one of json|dotenv|k8s-secret")
 	}
 	return nil
 }
@@ -0,0 +1,435 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package filestore implements an envsec store that keeps environment
+// variables in a local file encrypted with age (https://age-encryption.org).
+// It needs neither network access nor a Jetify account, which makes it useful
+// for offline development and CI.
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/pkg/errors"
+	"go.jetpack.io/envsec/pkg/envsec"
+	"go.jetpack.io/pkg/auth/session"
+	"go.jetpack.io/pkg/envvar"
+)
+
+const fileVersion = 1
+
+// maxHistory is how many versions of each variable are kept.
+const maxHistory = 20
+
+// FileStore stores the environment variables of every environment of a
+// project in a single age encrypted file.
+//
+// Files are encrypted with a passphrase if one is set. Otherwise they're
+// encrypted to an age X25519 identity, which is generated the first time it's
+// needed, and to any recipients listed in a <Path>.recipients file, one per
+// line. Committing the recipients file alongside the encrypted file lets a
+// team share secrets through the repository.
+type FileStore struct {
+	// Path of the encrypted file. Relative paths are relative to the working
+	// directory. Defaults to <user config dir>/envsec/<project id>.age
+	Path string
+	// Passphrase to encrypt the file with. Defaults to $ENVSEC_PASSPHRASE.
+	Passphrase string
+	// IdentityFile is the age identity used when there's no passphrase.
+	// Defaults to $ENVSEC_AGE_IDENTITY_FILE, or
+	// <user config dir>/envsec/age-key.txt
+	IdentityFile string
+
+	stderr io.Writer
+	// scryptWorkFactor overrides age's default work factor, which makes tests
+	// slow.
+	scryptWorkFactor int
+}
+
+// FileStore implements interfaces Store, Versioned, Timestamped and MetadataStore (compile-time check)
+var (
+	_ envsec.Store         = (*FileStore)(nil)
+	_ envsec.Versioned     = (*FileStore)(nil)
+	_ envsec.Timestamped   = (*FileStore)(nil)
+	_ envsec.MetadataStore = (*FileStore)(nil)
+)
+
+// contents is what the encrypted file contains.
+type contents struct {
+	Version int `json:"version"`
+	// Environments maps environment names to their variables.
+	Environments map[string]map[string]string `json:"environments"`
+	// History maps environment names to the previous versions of their
+	// variables, oldest first. The last version is the current one.
+	History map[string]map[string][]envsec.EnvVarVersion `json:"history,omitempty"`
+	// Metadata maps environment names to the metadata of their variables.
+	Metadata map[string]map[string]envsec.EnvVarMetadata `json:"metadata,omitempty"`
+}
+
+// InitForUser resolves the store's defaults. The file store doesn't need a
+// login, so it never returns a token.
+func (f *FileStore) InitForUser(_ context.Context, e *envsec.Envsec) (*session.Token, error) {
+	if f.Path != "" && !filepath.IsAbs(f.Path) {
+		f.Path = filepath.Join(e.WorkingDir, f.Path)
+	}
+	if f.Passphrase == "" {
+		f.Passphrase = envvar.Get("ENVSEC_PASSPHRASE", "")
+	}
+	if f.IdentityFile == "" {
+		f.IdentityFile = envvar.Get("ENVSEC_AGE_IDENTITY_FILE", "")
+	}
+	f.stderr = e.Stderr
+	return nil, nil
+}
+
+func (f *FileStore) List(ctx context.Context, envID envsec.EnvID) ([]envsec.EnvVar, error) {
+	c, err := f.read(envID)
+	if err != nil {
+		return nil, err
+	}
+	result := []envsec.EnvVar{}
+	for name, value := range c.Environments[envID.EnvName] {
+		result = append(result, c.envVar(envID.EnvName, name, value))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func (f *FileStore) Set(ctx context.Context, envID envsec.EnvID, name string, value string) error {
+	return f.SetAll(ctx, envID, map[string]string{name: value})
+}
+
+func (f *FileStore) SetAll(ctx context.Context, envID envsec.EnvID, values map[string]string) error {
+	return f.update(envID, func(vars map[string]string, _ map[string]envsec.EnvVarMetadata) {
+		for name, value := range values {
+			vars[name] = value
+		}
+	})
+}
+
+func (f *FileStore) SetAllWithMetadata(ctx context.Context, envID envsec.EnvID, vars []envsec.EnvVar) error {
+	return f.update(envID, func(values map[string]string, metadata map[string]envsec.EnvVarMetadata) {
+		for _, v := range vars {
+			values[v.Name] = v.Value
+			metadata[v.Name] = v.EnvVarMetadata
+		}
+	})
+}
+
+func (f *FileStore) Get(ctx context.Context, envID envsec.EnvID, name string) (string, error) {
+	c, err := f.read(envID)
+	if err != nil {
+		return "", err
+	}
+	return c.Environments[envID.EnvName][name], nil
+}
+
+func (f *FileStore) GetAll(ctx context.Context, envID envsec.EnvID, names []string) ([]envsec.EnvVar, error) {
+	c, err := f.read(envID)
+	if err != nil {
+		return nil, err
+	}
+	vars := c.Environments[envID.EnvName]
+	result := []envsec.EnvVar{}
+	for _, name := range names {
+		if value, ok := vars[name]; ok {
+			result = append(result, c.envVar(envID.EnvName, name, value))
+		}
+	}
+	return result, nil
+}
+
+func (f *FileStore) Delete(ctx context.Context, envID envsec.EnvID, name string) error {
+	return f.DeleteAll(ctx, envID, []string{name})
+}
+
+func (f *FileStore) DeleteAll(ctx context.Context, envID envsec.EnvID, names []string) error {
+	return f.update(envID, func(vars map[string]string, _ map[string]envsec.EnvVarMetadata) {
+		for _, name := range names {
+			delete(vars, name)
+		}
+	})
+}
+
+func (f *FileStore) History(
+	ctx context.Context,
+	envID envsec.EnvID,
+	name string,
+) ([]envsec.EnvVarVersion, error) {
+	c, err := f.read(envID)
+	if err != nil {
+		return nil, err
+	}
+	versions := c.History[envID.EnvName][name]
+	if versions == nil {
+		versions = []envsec.EnvVarVersion{}
+	}
+	return versions, nil
+}
+
+// LastModified returns the time of the current version of each variable.
+// Variables set before the file kept history have no time.
+func (f *FileStore) LastModified(_ context.Context, envID envsec.EnvID) (map[string]time.Time, error) {
+	c, err := f.read(envID)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]time.Time{}
+	for name := range c.Environments[envID.EnvName] {
+		versions := c.History[envID.EnvName][name]
+		if len(versions) > 0 && !versions[len(versions)-1].Deleted {
+			result[name] = versions[len(versions)-1].Timestamp
+		}
+	}
+	return result, nil
+}
+
+func (f *FileStore) path(envID envsec.EnvID) (string, error) {
+	if f.Path != "" {
+		return f.Path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return filepath.Join(dir, "envsec", envID.ProjectID+".age"), nil
+}
+
+// read decrypts the file. A file that doesn't exist yet has no variables.
+func (f *FileStore) read(envID envsec.EnvID) (*contents, error) {
+	c := &contents{
+		Version:      fileVersion,
+		Environments: map[string]map[string]string{},
+	}
+	path, err := f.path(envID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	identities, err := f.identities()
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(data)), identities...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt %s", path)
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt %s", path)
+	}
+	if err := json.Unmarshal(plaintext, c); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+	if c.Version > fileVersion {
+		return nil, errors.Errorf(
+			"%s was written by a newer version of envsec, please upgrade",
+			path,
+		)
+	}
+	if c.Environments == nil {
+		c.Environments = map[string]map[string]string{}
+	}
+	return c, nil
+}
+
+// update applies fn to the variables of the environment and their metadata,
+// and writes the file back. The metadata of deleted variables is dropped.
+//
+// The file is locked from before it's read until it's written, so that
+// concurrent updates, such as two envsec set running at once, apply one
+// after the other instead of one of them being lost.
+func (f *FileStore) update(
+	envID envsec.EnvID,
+	fn func(vars map[string]string, metadata map[string]envsec.EnvVarMetadata),
+) error {
+	unlock, err := f.lock(envID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	c, err := f.read(envID)
+	if err != nil {
+		return err
+	}
+	old := c.Environments[envID.EnvName]
+	vars := make(map[string]string, len(old))
+	for name, value := range old {
+		vars[name] = value
+	}
+	metadata := map[string]envsec.EnvVarMetadata{}
+	for name, m := range c.Metadata[envID.EnvName] {
+		metadata[name] = m
+	}
+	fn(vars, metadata)
+	c.recordHistory(envID.EnvName, old, vars)
+	if len(vars) == 0 {
+		delete(c.Environments, envID.EnvName)
+	} else {
+		c.Environments[envID.EnvName] = vars
+	}
+	for name, m := range metadata {
+		if _, ok := vars[name]; !ok || m.IsZero() {
+			delete(metadata, name)
+		}
+	}
+	if len(metadata) == 0 {
+		delete(c.Metadata, envID.EnvName)
+	} else {
+		if c.Metadata == nil {
+			c.Metadata = map[string]map[string]envsec.EnvVarMetadata{}
+		}
+		c.Metadata[envID.EnvName] = metadata
+	}
+	c.Version = fileVersion
+	return f.write(envID, c)
+}
+
+// lock takes an exclusive lock on <path>.lock, creating it if needed, and
+// returns the function that releases it. The lock file is never removed,
+// since removing it while another process waits for it would let a third
+// process lock a new file with the same name.
+func (f *FileStore) lock(envID envsec.EnvID) (unlock func(), err error) {
+	path, err := f.path(envID)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	file, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, errors.Wrapf(err, "failed to lock %s", file.Name())
+	}
+	return func() { file.Close() }, nil
+}
+
+func (c *contents) envVar(envName string, name string, value string) envsec.EnvVar {
+	return envsec.EnvVar{
+		Name:           name,
+		Value:          value,
+		EnvVarMetadata: c.Metadata[envName][name],
+	}
+}
+
+// recordHistory adds a version for every variable of the environment that
+// changed from old to vars.
+func (c *contents) recordHistory(envName string, old map[string]string, vars map[string]string) {
+	now := time.Now().UTC()
+	author := currentUser()
+	add := func(name string, version envsec.EnvVarVersion) {
+		if c.History == nil {
+			c.History = map[string]map[string][]envsec.EnvVarVersion{}
+		}
+		if c.History[envName] == nil {
+			c.History[envName] = map[string][]envsec.EnvVarVersion{}
+		}
+		versions := c.History[envName][name]
+		version.Version = 1
+		if len(versions) > 0 {
+			version.Version = versions[len(versions)-1].Version + 1
+		}
+		version.Timestamp = now
+		version.Author = author
+		versions = append(versions, version)
+		if len(versions) > maxHistory {
+			versions = versions[len(versions)-maxHistory:]
+		}
+		c.History[envName][name] = versions
+	}
+
+	for name, value := range vars {
+		if oldValue, ok := old[name]; !ok || oldValue != value {
+			add(name, envsec.EnvVarVersion{Value: value})
+		}
+	}
+	for name := range old {
+		if _, ok := vars[name]; !ok {
+			add(name, envsec.EnvVarVersion{Deleted: true})
+		}
+	}
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+func (f *FileStore) write(envID envsec.EnvID, c *contents) error {
+	path, err := f.path(envID)
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(c)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	recipients, err := f.recipients(path)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	armored := armor.NewWriter(&buf)
+	w, err := age.Encrypt(armored, recipients...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := w.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := armored.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place,
+// so that an interrupted write never leaves a corrupt file behind.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return errors.WithStack(err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.WithStack(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp.Name(), path))
+}
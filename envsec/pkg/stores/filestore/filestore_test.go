@@ -0,0 +1,246 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.jetpack.io/envsec/pkg/envsec"
+)
+
+func newStore(t *testing.T, passphrase string) (*FileStore, string) {
+	t.Helper()
+	dir := t.TempDir()
+	store := &FileStore{
+		Path:         "secrets.age",
+		Passphrase:   passphrase,
+		IdentityFile: filepath.Join(dir, "age-key.txt"),
+
+		scryptWorkFactor: 10,
+	}
+	_, err := store.InitForUser(context.Background(), &envsec.Envsec{WorkingDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, dir
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, passphrase := range []string{"", "correct horse battery staple"} {
+		t.Run("passphrase="+passphrase, func(t *testing.T) {
+			ctx := context.Background()
+			store, dir := newStore(t, passphrase)
+			dev := envsec.EnvID{ProjectID: "proj", EnvName: "dev"}
+			prod := envsec.EnvID{ProjectID: "proj", EnvName: "prod"}
+
+			if err := store.SetAll(ctx, dev, map[string]string{"B": "2", "A": "1", "C": "3"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Set(ctx, prod, "A", "prod"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Delete(ctx, dev, "C"); err != nil {
+				t.Fatal(err)
+			}
+
+			vars, err := store.List(ctx, dev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := []envsec.EnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}}
+			if !reflect.DeepEqual(vars, expected) {
+				t.Errorf("Expected %v, but got %v", expected, vars)
+			}
+			if value, err := store.Get(ctx, prod, "A"); err != nil || value != "prod" {
+				t.Errorf("Expected prod, but got %q (%v)", value, err)
+			}
+			if value, err := store.Get(ctx, prod, "B"); err != nil || value != "" {
+				t.Errorf("Expected an empty value, but got %q (%v)", value, err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(dir, "secrets.age"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), "prod") {
+				t.Error("Expected the file to be encrypted")
+			}
+		})
+	}
+}
+
+func TestWrongPassphrase(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore(t, "right")
+	envID := envsec.EnvID{ProjectID: "proj", EnvName: "dev"}
+	if err := store.Set(ctx, envID, "A", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	store.Passphrase = "wrong"
+	if _, err := store.List(ctx, envID); err == nil {
+		t.Error("Expected decrypting with the wrong passphrase to fail")
+	}
+}
+
+func TestHistoryAndRollback(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore(t, "")
+	e := &envsec.Envsec{
+		EnvID:  envsec.EnvID{ProjectID: "proj", EnvName: "dev"},
+		Stderr: io.Discard,
+		Store:  store,
+	}
+
+	if err := e.Set(ctx, "A", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetMap(ctx, map[string]string{"A": "2", "B": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.DeleteAll(ctx, "A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Rollback(ctx, "A", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := e.History(ctx, "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := []string{}
+	for _, v := range versions {
+		if v.Timestamp.IsZero() {
+			t.Errorf("Expected version %d to have a timestamp", v.Version)
+		}
+		if v.Deleted {
+			summary = append(summary, fmt.Sprintf("%d:deleted", v.Version))
+		} else {
+			summary = append(summary, fmt.Sprintf("%d:%s", v.Version, v.Value))
+		}
+	}
+	expected := []string{"1:1", "2:2", "3:deleted", "4:1"}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Errorf("Expected %v, but got %v", expected, summary)
+	}
+
+	if err := e.Rollback(ctx, "A", 7); err == nil {
+		t.Error("Expected rolling back to a missing version to fail")
+	}
+}
+
+func TestLastModified(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore(t, "")
+	envID := envsec.EnvID{ProjectID: "proj", EnvName: "dev"}
+
+	before := time.Now()
+	if err := store.SetAll(ctx, envID, map[string]string{"A": "1", "B": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, envID, "B"); err != nil {
+		t.Fatal(err)
+	}
+
+	modified, err := store.LastModified(ctx, envID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modified) != 1 || modified["A"].Before(before.Truncate(time.Second)) {
+		t.Errorf("Expected only A, modified after %v, but got %v", before, modified)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore(t, "")
+	dev := envsec.EnvID{ProjectID: "proj", EnvName: "dev"}
+
+	metadata := envsec.EnvVarMetadata{
+		Description: "Primary database",
+		Owner:       "data-team",
+		Sensitivity: envsec.SensitivitySecret,
+		Tags:        map[string]string{"tier": "1"},
+	}
+	err := store.SetAllWithMetadata(ctx, dev, []envsec.EnvVar{
+		{Name: "DATABASE_URL", Value: "postgres://", EnvVarMetadata: metadata},
+		{Name: "LOG_LEVEL", Value: "info"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Setting only the value keeps the metadata.
+	if err := store.Set(ctx, dev, "DATABASE_URL", "postgres://new"); err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := store.GetAll(ctx, dev, []string{"DATABASE_URL", "LOG_LEVEL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []envsec.EnvVar{
+		{Name: "DATABASE_URL", Value: "postgres://new", EnvVarMetadata: metadata},
+		{Name: "LOG_LEVEL", Value: "info"},
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, but got %v", expected, vars)
+	}
+
+	// Deleting a variable deletes its metadata.
+	if err := store.Delete(ctx, dev, "DATABASE_URL"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(ctx, dev, "DATABASE_URL", "postgres://"); err != nil {
+		t.Fatal(err)
+	}
+	vars, err = store.List(ctx, dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !vars[0].IsZero() {
+		t.Errorf("Expected no metadata, but got %v", vars[0].EnvVarMetadata)
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	ctx := context.Background()
+	_, dir := newStore(t, "passphrase")
+	dev := envsec.EnvID{ProjectID: "proj", EnvName: "dev"}
+
+	// Every store opens the lock file separately, like separate envsec
+	// processes would.
+	const n = 50
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			store := &FileStore{
+				Path:             filepath.Join(dir, "secrets.age"),
+				Passphrase:       "passphrase",
+				scryptWorkFactor: 10,
+			}
+			errs <- store.Set(ctx, dev, fmt.Sprintf("VAR_%d", i), "value")
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, _ := newStore(t, "passphrase")
+	store.Path = filepath.Join(dir, "secrets.age")
+	vars, err := store.List(ctx, dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != n {
+		t.Errorf("Expected %d variables, but got %v", n, vars)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package filestore
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"filippo.io/age"
+	"github.com/pkg/errors"
+)
+
+const recipientsSuffix = ".recipients"
+
+// identities returns the identities that can decrypt the file.
+func (f *FileStore) identities() ([]age.Identity, error) {
+	if f.Passphrase != "" {
+		identity, err := age.NewScryptIdentity(f.Passphrase)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return []age.Identity{identity}, nil
+	}
+
+	path, err := f.identityFile()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.Errorf(
+			"no age identity found at %s. Set ENVSEC_AGE_IDENTITY_FILE to the "+
+				"identity the file was encrypted to, or ENVSEC_PASSPHRASE if it was "+
+				"encrypted with a passphrase",
+			path,
+		)
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	identities, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse age identity %s", path)
+	}
+	return identities, nil
+}
+
+// recipients returns who to encrypt the file at path to. Without a
+// passphrase that's the user's own identity, which is generated if needed,
+// plus everyone in the file's recipients file.
+func (f *FileStore) recipients(path string) ([]age.Recipient, error) {
+	if f.Passphrase != "" {
+		// age doesn't allow passphrases to be combined with other recipients.
+		recipient, err := age.NewScryptRecipient(f.Passphrase)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if f.scryptWorkFactor != 0 {
+			recipient.SetWorkFactor(f.scryptWorkFactor)
+		}
+		return []age.Recipient{recipient}, nil
+	}
+
+	identity, err := f.loadOrGenerateIdentity()
+	if err != nil {
+		return nil, err
+	}
+	recipients := []age.Recipient{identity.Recipient()}
+
+	data, err := os.ReadFile(path + recipientsSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return recipients, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	others, err := age.ParseRecipients(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path+recipientsSuffix)
+	}
+	return append(recipients, others...), nil
+}
+
+// loadOrGenerateIdentity returns the user's X25519 identity, generating and
+// saving a new one if there's none yet.
+func (f *FileStore) loadOrGenerateIdentity() (*age.X25519Identity, error) {
+	path, err := f.identityFile()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		identities, err := age.ParseIdentities(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse age identity %s", path)
+		}
+		for _, identity := range identities {
+			if x25519, ok := identity.(*age.X25519Identity); ok {
+				return x25519, nil
+			}
+		}
+		return nil, errors.Errorf("%s contains no X25519 age identity", path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.WithStack(err)
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	key := fmt.Sprintf("# public key: %s\n%s\n", identity.Recipient(), identity)
+	// O_EXCL so that we never overwrite an identity created concurrently.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if _, err := file.WriteString(key); err != nil {
+		file.Close()
+		return nil, errors.WithStack(err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if f.stderr != nil {
+		fmt.Fprintf(
+			f.stderr,
+			"Generated a new age identity in %s. Back it up, secrets can't be "+
+				"decrypted without it. Its public key is %s\n",
+			path,
+			identity.Recipient(),
+		)
+	}
+	return identity, nil
+}
+
+func (f *FileStore) identityFile() (string, error) {
+	if f.IdentityFile != "" {
+		return f.IdentityFile, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return filepath.Join(dir, "envsec", "age-key.txt"), nil
+}
@@ -15,8 +15,26 @@ type JetpackAPIStore struct {
 	client secretsv1alpha1connect.SecretsServiceClient
 }
 
-// JetpackAPIStore implements interface Store (compile-time check)
-var _ envsec.Store = (*JetpackAPIStore)(nil)
+// JetpackAPIMetadataStore is a JetpackAPIStore that also keeps the metadata
+// of variables, in the envsec.MetadataVarName secret of each environment.
+//
+// The API can only list all the secrets of a project, and has no way to check
+// that a secret didn't change before writing it. So every write of metadata,
+// and every delete, lists the project first, and if two clients change
+// metadata in the same environment at the same time, the changes of one of
+// them can be lost. That's why it's only used when asked for, with
+// jetify://?metadata=true.
+type JetpackAPIMetadataStore struct {
+	JetpackAPIStore
+}
+
+// JetpackAPIStore implements interface Store, and JetpackAPIMetadataStore
+// interfaces Store and MetadataStore (compile-time check)
+var (
+	_ envsec.Store         = (*JetpackAPIStore)(nil)
+	_ envsec.Store         = (*JetpackAPIMetadataStore)(nil)
+	_ envsec.MetadataStore = (*JetpackAPIMetadataStore)(nil)
+)
 
 func (j *JetpackAPIStore) InitForUser(
 	ctx context.Context,
@@ -41,25 +59,67 @@ func (j *JetpackAPIStore) InitForUser(
 }
 
 func (j JetpackAPIStore) List(ctx context.Context, envID envsec.EnvID) ([]envsec.EnvVar, error) {
+	vars, _, err := j.list(ctx, envID)
+	return vars, err
+}
+
+func (j JetpackAPIMetadataStore) List(ctx context.Context, envID envsec.EnvID) ([]envsec.EnvVar, error) {
+	vars, metadata, err := j.listWithMetadata(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range vars {
+		vars[i].EnvVarMetadata = metadata[vars[i].Name]
+	}
+	return vars, nil
+}
+
+// list returns the variables of an environment without their metadata, and
+// the value of the envsec.MetadataVarName secret.
+func (j JetpackAPIStore) list(ctx context.Context, envID envsec.EnvID) ([]envsec.EnvVar, string, error) {
 	resp, err := j.client.ListSecrets(
 		ctx,
 		connect.NewRequest(&secretsv1alpha1.ListSecretsRequest{ProjectId: envID.ProjectID}),
 	)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	result := []envsec.EnvVar{}
+	metadata := ""
 	for _, secret := range resp.Msg.Secrets {
-		if v := secret.EnvironmentValues[envID.EnvName]; len(v) > 0 {
-			result = append(
-				result, envsec.EnvVar{
-					Name:  secret.Name,
-					Value: string(v),
-				},
-			)
+		v := secret.EnvironmentValues[envID.EnvName]
+		if len(v) == 0 {
+			continue
 		}
+		if secret.Name == envsec.MetadataVarName {
+			metadata = string(v)
+			continue
+		}
+		result = append(
+			result, envsec.EnvVar{
+				Name:  secret.Name,
+				Value: string(v),
+			},
+		)
 	}
-	return result, nil
+	return result, metadata, nil
+}
+
+// listWithMetadata returns the variables of an environment without their
+// metadata, and the metadata kept in the envsec.MetadataVarName secret.
+func (j JetpackAPIMetadataStore) listWithMetadata(
+	ctx context.Context,
+	envID envsec.EnvID,
+) ([]envsec.EnvVar, map[string]envsec.EnvVarMetadata, error) {
+	vars, value, err := j.list(ctx, envID)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata, err := envsec.DecodeMetadata(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return vars, metadata, nil
 }
 
 func (j JetpackAPIStore) Set(ctx context.Context, envID envsec.EnvID, name string, value string) error {
@@ -82,21 +142,7 @@ func (j JetpackAPIStore) Set(ctx context.Context, envID envsec.EnvID, name strin
 func (j JetpackAPIStore) SetAll(ctx context.Context, envID envsec.EnvID, values map[string]string) error {
 	patchActions := []*secretsv1alpha1.Action{}
 	for name, value := range values {
-		patchActions = append(
-			patchActions, &secretsv1alpha1.Action{
-				Action: &secretsv1alpha1.Action_PatchSecret{
-					PatchSecret: &secretsv1alpha1.PatchSecretRequest{
-						ProjectId: envID.ProjectID,
-						Secret: &secretsv1alpha1.Secret{
-							Name: name,
-							EnvironmentValues: map[string][]byte{
-								envID.EnvName: []byte(value),
-							},
-						},
-					},
-				},
-			},
-		)
+		patchActions = append(patchActions, patchAction(envID, name, value))
 	}
 
 	_, err := j.client.Batch(
@@ -105,6 +151,37 @@ func (j JetpackAPIStore) SetAll(ctx context.Context, envID envsec.EnvID, values
 	return err
 }
 
+// SetAllWithMetadata sets the values and updates the metadata secret in a
+// single batch. The metadata secret is read first, see
+// JetpackAPIMetadataStore for why changes to it can be lost. Values aren't
+// affected.
+func (j JetpackAPIMetadataStore) SetAllWithMetadata(
+	ctx context.Context,
+	envID envsec.EnvID,
+	vars []envsec.EnvVar,
+) error {
+	_, metadata, err := j.listWithMetadata(ctx, envID)
+	if err != nil {
+		return err
+	}
+	values := map[string]string{}
+	for _, v := range vars {
+		values[v.Name] = v.Value
+		metadata[v.Name] = v.EnvVarMetadata
+	}
+	actions, err := metadataAction(envID, metadata)
+	if err != nil {
+		return err
+	}
+	for name, value := range values {
+		actions = append(actions, patchAction(envID, name, value))
+	}
+	_, err = j.client.Batch(
+		ctx, connect.NewRequest(&secretsv1alpha1.BatchRequest{Actions: actions}),
+	)
+	return err
+}
+
 func (j JetpackAPIStore) Get(ctx context.Context, envID envsec.EnvID, name string) (string, error) {
 	vars, err := j.List(ctx, envID)
 	if err != nil {
@@ -123,6 +200,23 @@ func (j JetpackAPIStore) GetAll(ctx context.Context, envID envsec.EnvID, names [
 	if err != nil {
 		return nil, err
 	}
+	return filterNames(vars, names), nil
+}
+
+func (j JetpackAPIMetadataStore) GetAll(
+	ctx context.Context,
+	envID envsec.EnvID,
+	names []string,
+) ([]envsec.EnvVar, error) {
+	vars, err := j.List(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+	return filterNames(vars, names), nil
+}
+
+// filterNames returns the variables with the given names.
+func filterNames(vars []envsec.EnvVar, names []string) []envsec.EnvVar {
 	result := []envsec.EnvVar{}
 	for _, v := range vars {
 		for _, name := range names {
@@ -131,36 +225,17 @@ func (j JetpackAPIStore) GetAll(ctx context.Context, envID envsec.EnvID, names [
 			}
 		}
 	}
-	return result, nil
+	return result
 }
 
 func (j JetpackAPIStore) Delete(ctx context.Context, envID envsec.EnvID, name string) error {
-	_, err := j.client.DeleteSecret(
-		ctx, connect.NewRequest(
-			&secretsv1alpha1.DeleteSecretRequest{
-				ProjectId:    envID.ProjectID,
-				SecretName:   name,
-				Environments: []string{envID.EnvName},
-			},
-		),
-	)
-	return err
+	return j.DeleteAll(ctx, envID, []string{name})
 }
 
 func (j JetpackAPIStore) DeleteAll(ctx context.Context, envID envsec.EnvID, names []string) error {
 	deleteActions := []*secretsv1alpha1.Action{}
 	for _, name := range names {
-		deleteActions = append(
-			deleteActions, &secretsv1alpha1.Action{
-				Action: &secretsv1alpha1.Action_DeleteSecret{
-					DeleteSecret: &secretsv1alpha1.DeleteSecretRequest{
-						ProjectId:    envID.ProjectID,
-						SecretName:   name,
-						Environments: []string{envID.EnvName},
-					},
-				},
-			},
-		)
+		deleteActions = append(deleteActions, deleteAction(envID, name))
 	}
 
 	_, err := j.client.Batch(
@@ -168,3 +243,88 @@ func (j JetpackAPIStore) DeleteAll(ctx context.Context, envID envsec.EnvID, name
 	)
 	return err
 }
+
+func (j JetpackAPIMetadataStore) Delete(ctx context.Context, envID envsec.EnvID, name string) error {
+	return j.DeleteAll(ctx, envID, []string{name})
+}
+
+// DeleteAll deletes variables, and their metadata if they have some. Finding
+// out whether they do lists the environment, and the metadata secret is only
+// written when it changes.
+func (j JetpackAPIMetadataStore) DeleteAll(ctx context.Context, envID envsec.EnvID, names []string) error {
+	_, metadata, err := j.listWithMetadata(ctx, envID)
+	if err != nil {
+		return err
+	}
+	deleteActions := []*secretsv1alpha1.Action{}
+	hadMetadata := false
+	for _, name := range names {
+		if _, ok := metadata[name]; ok {
+			hadMetadata = true
+			delete(metadata, name)
+		}
+		deleteActions = append(deleteActions, deleteAction(envID, name))
+	}
+	if hadMetadata {
+		actions, err := metadataAction(envID, metadata)
+		if err != nil {
+			return err
+		}
+		deleteActions = append(deleteActions, actions...)
+	}
+
+	_, err = j.client.Batch(
+		ctx, connect.NewRequest(&secretsv1alpha1.BatchRequest{Actions: deleteActions}),
+	)
+	return err
+}
+
+func patchAction(envID envsec.EnvID, name string, value string) *secretsv1alpha1.Action {
+	return &secretsv1alpha1.Action{
+		Action: &secretsv1alpha1.Action_PatchSecret{
+			PatchSecret: &secretsv1alpha1.PatchSecretRequest{
+				ProjectId: envID.ProjectID,
+				Secret: &secretsv1alpha1.Secret{
+					Name: name,
+					EnvironmentValues: map[string][]byte{
+						envID.EnvName: []byte(value),
+					},
+				},
+			},
+		},
+	}
+}
+
+func deleteAction(envID envsec.EnvID, name string) *secretsv1alpha1.Action {
+	return &secretsv1alpha1.Action{
+		Action: &secretsv1alpha1.Action_DeleteSecret{
+			DeleteSecret: &secretsv1alpha1.DeleteSecretRequest{
+				ProjectId:    envID.ProjectID,
+				SecretName:   name,
+				Environments: []string{envID.EnvName},
+			},
+		},
+	}
+}
+
+// metadataAction returns the action that stores metadata in the
+// envsec.MetadataVarName secret, or deletes the secret if no variable has
+// metadata.
+func metadataAction(
+	envID envsec.EnvID,
+	metadata map[string]envsec.EnvVarMetadata,
+) ([]*secretsv1alpha1.Action, error) {
+	for name, m := range metadata {
+		if m.IsZero() {
+			delete(metadata, name)
+		}
+	}
+	if len(metadata) == 0 {
+		return []*secretsv1alpha1.Action{deleteAction(envID, envsec.MetadataVarName)}, nil
+	}
+	encoded, err := envsec.EncodeMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+	return []*secretsv1alpha1.Action{patchAction(envID, envsec.MetadataVarName, encoded)}, nil
+}
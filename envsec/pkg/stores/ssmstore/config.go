@@ -14,6 +14,9 @@ type SSMConfig struct {
 	SecretAccessKey string
 	SessionToken    string
 	KmsKeyID        string
+	// PathPrefix is the path under which all variables are stored, one
+	// directory per org. Defaults to /jetpack-data/env
+	PathPrefix string
 
 	VarPathFn       func(envId envsec.EnvID, varName string) string
 	PathNamespaceFn func(envId envsec.EnvID) string
@@ -35,7 +38,11 @@ func (c *SSMConfig) pathNamespace(envID envsec.EnvID) string {
 	if c.PathNamespaceFn != nil {
 		return c.PathNamespaceFn(envID)
 	}
-	return path.Join(pathPrefix, envID.OrgID)
+	prefix := c.PathPrefix
+	if prefix == "" {
+		prefix = pathPrefix
+	}
+	return path.Join(prefix, envID.OrgID)
 }
 
 func (c *SSMConfig) hasDefaultPaths() bool {
@@ -0,0 +1,205 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package ssmstore
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/envsec/pkg/envsec"
+)
+
+// Descriptions are kept in the parameters' own description. The rest of the
+// metadata is kept in tags, since tags can't hold JSON. Parameters with such
+// tags are also tagged with metadataTag, so that they can be found without
+// listing the tags of every parameter.
+const (
+	metadataTag       = "envsec-metadata"
+	ownerTag          = "envsec-owner"
+	expiresTag        = "envsec-expires"
+	sensitivityTag    = "envsec-sensitivity"
+	userTagPrefix     = "envsec-tag:"
+	metadataTagPrefix = "envsec-"
+)
+
+// metadataTags returns the tags that hold metadata, other than the
+// description.
+func metadataTags(m envsec.EnvVarMetadata) []types.Tag {
+	tags := []types.Tag{}
+	add := func(key string, value string) {
+		tags = append(tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	if m.Owner != "" {
+		add(ownerTag, m.Owner)
+	}
+	if m.Expires != nil {
+		add(expiresTag, m.Expires.UTC().Format(time.RFC3339))
+	}
+	if m.Sensitivity != "" {
+		add(sensitivityTag, string(m.Sensitivity))
+	}
+	for key, value := range m.Tags {
+		add(userTagPrefix+key, value)
+	}
+	if len(tags) > 0 {
+		add(metadataTag, "true")
+	}
+	return tags
+}
+
+// metadataFromTags is the inverse of metadataTags.
+func metadataFromTags(tags []types.Tag) envsec.EnvVarMetadata {
+	m := envsec.EnvVarMetadata{}
+	for _, tag := range tags {
+		key, value := aws.ToString(tag.Key), aws.ToString(tag.Value)
+		switch {
+		case key == ownerTag:
+			m.Owner = value
+		case key == expiresTag:
+			if t, err := time.Parse(time.RFC3339, value); err == nil {
+				m.Expires = &t
+			}
+		case key == sensitivityTag:
+			m.Sensitivity = envsec.Sensitivity(value)
+		case strings.HasPrefix(key, userTagPrefix):
+			if m.Tags == nil {
+				m.Tags = map[string]string{}
+			}
+			m.Tags[strings.TrimPrefix(key, userTagPrefix)] = value
+		}
+	}
+	return m
+}
+
+// setMetadataTags replaces the metadata tags of a parameter.
+func (s *parameterStore) setMetadataTags(ctx context.Context, path string, m envsec.EnvVarMetadata) error {
+	resp, err := s.client.ListTagsForResource(ctx, &ssm.ListTagsForResourceInput{
+		ResourceId:   aws.String(path),
+		ResourceType: types.ResourceTypeForTaggingParameter,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tags := metadataTags(m)
+	keys := lo.SliceToMap(tags, func(tag types.Tag) (string, bool) {
+		return aws.ToString(tag.Key), true
+	})
+	removed := []string{}
+	for _, tag := range resp.TagList {
+		key := aws.ToString(tag.Key)
+		if strings.HasPrefix(key, metadataTagPrefix) && !keys[key] {
+			removed = append(removed, key)
+		}
+	}
+
+	if len(removed) > 0 {
+		_, err := s.client.RemoveTagsFromResource(ctx, &ssm.RemoveTagsFromResourceInput{
+			ResourceId:   aws.String(path),
+			ResourceType: types.ResourceTypeForTaggingParameter,
+			TagKeys:      removed,
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(tags) > 0 {
+		_, err := s.client.AddTagsToResource(ctx, &ssm.AddTagsToResourceInput{
+			ResourceId:   aws.String(path),
+			ResourceType: types.ResourceTypeForTaggingParameter,
+			Tags:         tags,
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// metadata returns the metadata of the parameters of an environment, by
+// variable name. Variables without metadata are left out.
+func (s *parameterStore) metadata(
+	ctx context.Context,
+	envID envsec.EnvID,
+) (map[string]envsec.EnvVarMetadata, error) {
+	results := map[string]envsec.EnvVarMetadata{}
+	err := s.describeParameters(ctx, s.buildFilters(envID), func(p types.ParameterMetadata) error {
+		if description := aws.ToString(p.Description); description != "" {
+			results[nameFromPath(aws.ToString(p.Name))] = envsec.EnvVarMetadata{
+				Description: description,
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	filters := append(s.buildFilters(envID), types.ParameterStringFilter{
+		Key:    aws.String("tag:" + metadataTag),
+		Values: []string{"true"},
+	})
+	err = s.describeParameters(ctx, filters, func(p types.ParameterMetadata) error {
+		resp, err := s.client.ListTagsForResource(ctx, &ssm.ListTagsForResourceInput{
+			ResourceId:   p.Name,
+			ResourceType: types.ResourceTypeForTaggingParameter,
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		name := nameFromPath(aws.ToString(p.Name))
+		m := metadataFromTags(resp.TagList)
+		m.Description = results[name].Description
+		results[name] = m
+		return nil
+	})
+	return results, err
+}
+
+func (s *parameterStore) describeParameters(
+	ctx context.Context,
+	filters []types.ParameterStringFilter,
+	fn func(p types.ParameterMetadata) error,
+) error {
+	paginator := ssm.NewDescribeParametersPaginator(s.client, &ssm.DescribeParametersInput{
+		ParameterFilters: filters,
+	})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, p := range resp.Parameters {
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// withMetadata fills in the metadata of vars.
+func (s *parameterStore) withMetadata(
+	ctx context.Context,
+	envID envsec.EnvID,
+	vars []envsec.EnvVar,
+) ([]envsec.EnvVar, error) {
+	if len(vars) == 0 {
+		return vars, nil
+	}
+	metadata, err := s.metadata(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range vars {
+		vars[i].EnvVarMetadata = metadata[vars[i].Name]
+	}
+	return vars, nil
+}
@@ -6,6 +6,7 @@ package ssmstore
 import (
 	"context"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
@@ -24,9 +25,13 @@ import (
 const emptyStringValuePlaceholder = "__###EMPTY_STRING###__"
 
 type parameter struct {
-	id          string
-	description string
-	tags        []types.Tag
+	id string
+	// description, if nil, keeps the description of an existing parameter.
+	description *string
+	// paramType, if empty, is SecureString for new parameters and keeps the
+	// type of existing ones.
+	paramType types.ParameterType
+	tags      []types.Tag
 }
 
 type parameterStore struct {
@@ -73,17 +78,21 @@ func (s *parameterStore) newParameter(ctx context.Context, param *parameter, val
 		return errors.New("parameter values are limited in size to 4KB")
 	}
 
+	paramType := param.paramType
+	if paramType == "" {
+		paramType = types.ParameterTypeSecureString
+	}
 	input := &ssm.PutParameterInput{
 		Name:        aws.String(param.id),
-		Description: aws.String(param.description),
-		Type:        types.ParameterTypeSecureString,
+		Description: param.description,
+		Type:        paramType,
 		Value:       awsSSMParamStoreValue(value),
 		Tags:        param.tags,
 	}
 
 	// Set the KmsKeyId only when it is present. Otherwise, aws sdk uses the default KMS key
 	// since we specify "SecureString" type.
-	if s.config.KmsKeyID != "" {
+	if s.config.KmsKeyID != "" && input.Type == types.ParameterTypeSecureString {
 		input.KeyId = aws.String(s.config.KmsKeyID)
 	}
 
@@ -103,10 +112,14 @@ func (s *parameterStore) newParameter(ctx context.Context, param *parameter, val
 func (s *parameterStore) overwriteParameterValue(ctx context.Context, v *parameter, value string) error {
 	input := &ssm.PutParameterInput{
 		Name:        aws.String(v.id),
-		Description: aws.String(v.description),
+		Description: v.description,
+		Type:        v.paramType,
 		Overwrite:   lo.ToPtr(true),
 		Value:       awsSSMParamStoreValue(value),
 	}
+	if s.config.KmsKeyID != "" && v.paramType == types.ParameterTypeSecureString {
+		input.KeyId = aws.String(s.config.KmsKeyID)
+	}
 	_, err := s.client.PutParameter(ctx, input)
 	return errors.WithStack(err)
 }
@@ -171,6 +184,27 @@ func (s *parameterStore) listByTags(ctx context.Context, envID envsec.EnvID) ([]
 	return s.getAll(ctx, envID, varNames)
 }
 
+// lastModified returns when each parameter of the environment was last
+// changed. It reads metadata only, so values aren't decrypted.
+func (s *parameterStore) lastModified(ctx context.Context, envID envsec.EnvID) (map[string]time.Time, error) {
+	req := &ssm.DescribeParametersInput{
+		ParameterFilters: s.buildFilters(envID),
+	}
+
+	results := map[string]time.Time{}
+	paginator := ssm.NewDescribeParametersPaginator(s.client, req)
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, p := range resp.Parameters {
+			results[nameFromPath(aws.ToString(p.Name))] = aws.ToTime(p.LastModifiedDate)
+		}
+	}
+	return results, nil
+}
+
 func (s *parameterStore) buildFilters(envID envsec.EnvID) []types.ParameterStringFilter {
 	filters := []types.ParameterStringFilter{
 		{
@@ -268,6 +302,36 @@ func (s *parameterStore) deleteAll(ctx context.Context, envID envsec.EnvID, varN
 	return multiErr
 }
 
+func (s *parameterStore) history(ctx context.Context, envID envsec.EnvID, name string) ([]envsec.EnvVarVersion, error) {
+	req := &ssm.GetParameterHistoryInput{
+		Name:           aws.String(s.config.varPath(envID, name)),
+		WithDecryption: lo.ToPtr(true),
+	}
+
+	results := []envsec.EnvVarVersion{}
+	paginator := ssm.NewGetParameterHistoryPaginator(s.client, req)
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		var notFound *types.ParameterNotFound
+		if errors.As(err, &notFound) {
+			// Deleting a parameter deletes its history too.
+			return results, nil
+		} else if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, p := range resp.Parameters {
+			results = append(results, envsec.EnvVarVersion{
+				Version:   int(p.Version),
+				Value:     awsSSMParamStoreValueToString(p.Value),
+				Timestamp: aws.ToTime(p.LastModifiedDate),
+				Author:    aws.ToString(p.LastModifiedUser),
+			})
+		}
+	}
+	// SSM returns versions oldest first.
+	return results, nil
+}
+
 // Implement interface Lister from text/collate
 type envVars []envsec.EnvVar
 
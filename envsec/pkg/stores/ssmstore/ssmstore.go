@@ -5,6 +5,7 @@ package ssmstore
 
 import (
 	"context"
+	"time"
 
 	cognitoTypes "github.com/aws/aws-sdk-go-v2/service/cognitoidentity/types"
 	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
@@ -17,13 +18,31 @@ import (
 )
 
 type SSMStore struct {
+	// Config, if set, is used as is with the default AWS credential chain,
+	// instead of credentials federated from the user's Jetify account.
+	Config *SSMConfig
+
 	store *parameterStore
 }
 
-// SSMStore implements interface Store (compile-time check)
-var _ envsec.Store = (*SSMStore)(nil)
+// SSMStore implements interfaces Store, Versioned, Timestamped and MetadataStore (compile-time check)
+var (
+	_ envsec.Store         = (*SSMStore)(nil)
+	_ envsec.Versioned     = (*SSMStore)(nil)
+	_ envsec.Timestamped   = (*SSMStore)(nil)
+	_ envsec.MetadataStore = (*SSMStore)(nil)
+)
 
 func (s *SSMStore) InitForUser(ctx context.Context, e *envsec.Envsec) (*session.Token, error) {
+	if s.Config != nil {
+		paramStore, err := newParameterStore(ctx, s.Config)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		s.store = paramStore
+		return nil, nil
+	}
+
 	client, err := e.AuthClient()
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -45,10 +64,15 @@ func (s *SSMStore) InitForUser(ctx context.Context, e *envsec.Envsec) (*session.
 }
 
 func (s *SSMStore) List(ctx context.Context, envID envsec.EnvID) ([]envsec.EnvVar, error) {
+	list := s.store.listByTags
 	if s.store.config.hasDefaultPaths() {
-		return s.store.listByPath(ctx, envID)
+		list = s.store.listByPath
+	}
+	vars, err := list(ctx, envID)
+	if err != nil {
+		return nil, err
 	}
-	return s.store.listByTags(ctx, envID)
+	return s.store.withMetadata(ctx, envID, vars)
 }
 
 func (s *SSMStore) Get(ctx context.Context, envID envsec.EnvID, name string) (string, error) {
@@ -63,7 +87,11 @@ func (s *SSMStore) Get(ctx context.Context, envID envsec.EnvID, name string) (st
 }
 
 func (s *SSMStore) GetAll(ctx context.Context, envID envsec.EnvID, names []string) ([]envsec.EnvVar, error) {
-	return s.store.getAll(ctx, envID, names)
+	vars, err := s.store.getAll(ctx, envID, names)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.withMetadata(ctx, envID, vars)
 }
 
 func (s *SSMStore) Set(
@@ -98,6 +126,33 @@ func (s *SSMStore) SetAll(ctx context.Context, envID envsec.EnvID, values map[st
 	return multiErr
 }
 
+func (s *SSMStore) SetAllWithMetadata(ctx context.Context, envID envsec.EnvID, vars []envsec.EnvVar) error {
+	var multiErr error
+	for _, v := range vars {
+		path := s.store.config.varPath(envID, v.Name)
+		// Config is stored unencrypted, so that it can be read in the AWS
+		// console without access to the KMS key.
+		paramType := types.ParameterTypeSecureString
+		if !v.IsSecret() {
+			paramType = types.ParameterTypeString
+		}
+		parameter := &parameter{
+			tags:        buildTags(envID, v.Name),
+			id:          path,
+			description: lo.ToPtr(v.Description),
+			paramType:   paramType,
+		}
+		err := s.store.newParameter(ctx, parameter, v.Value)
+		if err == nil {
+			err = s.store.setMetadataTags(ctx, path, v.EnvVarMetadata)
+		}
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+	return multiErr
+}
+
 func (s *SSMStore) Delete(ctx context.Context, envID envsec.EnvID, name string) error {
 	return s.DeleteAll(ctx, envID, []string{name})
 }
@@ -106,6 +161,18 @@ func (s *SSMStore) DeleteAll(ctx context.Context, envID envsec.EnvID, names []st
 	return s.store.deleteAll(ctx, envID, names)
 }
 
+func (s *SSMStore) History(
+	ctx context.Context,
+	envID envsec.EnvID,
+	name string,
+) ([]envsec.EnvVarVersion, error) {
+	return s.store.history(ctx, envID, name)
+}
+
+func (s *SSMStore) LastModified(ctx context.Context, envID envsec.EnvID) (map[string]time.Time, error) {
+	return s.store.lastModified(ctx, envID)
+}
+
 func buildTags(envID envsec.EnvID, varName string) []types.Tag {
 	tags := []types.Tag{}
 	if envID.ProjectID != "" {
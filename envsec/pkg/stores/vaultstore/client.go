@@ -0,0 +1,255 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package vaultstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// errCASMismatch is returned when a write's check-and-set version no longer
+// matches the secret's current version.
+var errCASMismatch = errors.New("secret was modified concurrently")
+
+// client is a minimal client for the parts of Vault's HTTP API the store uses.
+type client struct {
+	config *VaultConfig
+	token  string
+	// mountChecked is set once the mount is known to be a KV v2 mount.
+	mountChecked bool
+}
+
+// kvSecret is a version of a KV v2 secret.
+type kvSecret struct {
+	Data map[string]string
+	// Version is the secret's current version, 0 if it was never written.
+	Version int
+}
+
+// readSecret reads the latest version of a KV v2 secret. Secrets that don't
+// exist, or whose latest version was deleted, have no data. It returns an
+// error if the mount isn't a KV v2 mount.
+func (c *client) readSecret(ctx context.Context, secretPath string) (*kvSecret, error) {
+	return c.readSecretVersion(ctx, secretPath, 0)
+}
+
+// readSecretVersion reads a version of a KV v2 secret, or the latest one if
+// version is 0.
+func (c *client) readSecretVersion(ctx context.Context, secretPath string, version int) (*kvSecret, error) {
+	var resp struct {
+		Data struct {
+			Data     map[string]string `json:"data"`
+			Metadata *struct {
+				Version int `json:"version"`
+			} `json:"metadata"`
+		} `json:"data"`
+	}
+	apiPath := c.dataPath(secretPath)
+	if version != 0 {
+		apiPath += "?version=" + strconv.Itoa(version)
+	}
+	status, err := c.do(ctx, http.MethodGet, apiPath, nil, &resp)
+	switch {
+	case status == http.StatusNotFound && resp.Data.Metadata != nil:
+		// Vault responds with 404 when the latest version was deleted, but
+		// still includes its metadata, which we need for check-and-set.
+	case status == http.StatusNotFound:
+		// Either the secret was never written, or there's no KV v2 mount
+		// there, in which case writing with cas=0 would be wrong.
+		if err := c.checkMount(ctx, secretPath); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	}
+	secret := &kvSecret{Data: resp.Data.Data}
+	if resp.Data.Metadata != nil {
+		secret.Version = resp.Data.Metadata.Version
+	}
+	if secret.Data == nil {
+		secret.Data = map[string]string{}
+	}
+	return secret, nil
+}
+
+// kvVersionMetadata describes a version of a KV v2 secret.
+type kvVersionMetadata struct {
+	CreatedTime time.Time `json:"created_time"`
+	// DeletionTime is set if the version was deleted. Vault reports it as an
+	// empty string otherwise.
+	DeletionTime string `json:"deletion_time"`
+	Destroyed    bool   `json:"destroyed"`
+}
+
+// readVersions returns the metadata of every version of a KV v2 secret that
+// Vault still keeps, keyed by version. Secrets that don't exist have none.
+func (c *client) readVersions(ctx context.Context, secretPath string) (map[int]kvVersionMetadata, error) {
+	var resp struct {
+		Data struct {
+			Versions map[string]kvVersionMetadata `json:"versions"`
+		} `json:"data"`
+	}
+	apiPath := strings.Trim(c.config.Mount, "/") + "/metadata/" + strings.Trim(secretPath, "/")
+	status, err := c.do(ctx, http.MethodGet, apiPath, nil, &resp)
+	if status == http.StatusNotFound {
+		if err := c.checkMount(ctx, secretPath); err != nil {
+			return nil, err
+		}
+		return map[int]kvVersionMetadata{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	versions := make(map[int]kvVersionMetadata, len(resp.Data.Versions))
+	for key, metadata := range resp.Data.Versions {
+		version, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid version %q of secret %s", key, secretPath)
+		}
+		versions[version] = metadata
+	}
+	return versions, nil
+}
+
+// checkMount returns an error naming the mount and the secret's path unless
+// the mount is a KV v2 secrets engine. KV v1 mounts and paths with no mount
+// respond with 404 like secrets that were never written, so they'd otherwise
+// read as having no variables.
+func (c *client) checkMount(ctx context.Context, secretPath string) error {
+	if c.mountChecked {
+		return nil
+	}
+	var resp struct {
+		Data struct {
+			Type    string `json:"type"`
+			Options struct {
+				Version string `json:"version"`
+			} `json:"options"`
+		} `json:"data"`
+	}
+	mount := strings.Trim(c.config.Mount, "/")
+	_, err := c.do(ctx, http.MethodGet, "sys/internal/ui/mounts/"+mount, nil, &resp)
+	if err == nil && resp.Data.Type == "kv" && resp.Data.Options.Version == "2" {
+		c.mountChecked = true
+		return nil
+	}
+	msg := fmt.Sprintf(
+		"can't read secret %s: %s isn't a KV version 2 secrets engine mount in vault. "+
+			"Check the mount in the store URL",
+		secretPath, mount,
+	)
+	if err != nil {
+		return errors.Wrap(err, msg)
+	}
+	return errors.New(msg)
+}
+
+// writeSecret writes a new version of a KV v2 secret, provided its current
+// version is still cas.
+func (c *client) writeSecret(
+	ctx context.Context,
+	secretPath string,
+	data map[string]string,
+	cas int,
+) error {
+	body := map[string]any{
+		"data":    data,
+		"options": map[string]any{"cas": cas},
+	}
+	status, err := c.do(ctx, http.MethodPost, c.dataPath(secretPath), body, nil)
+	if status == http.StatusBadRequest && err != nil &&
+		strings.Contains(err.Error(), "check-and-set") {
+		return errCASMismatch
+	}
+	return err
+}
+
+// login exchanges credentials for a token with an auth method.
+func (c *client) login(ctx context.Context, mount string, body map[string]string) error {
+	var resp struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, "auth/"+mount+"/login", body, &resp); err != nil {
+		return errors.Wrapf(err, "failed to log in to vault with auth method %s", mount)
+	}
+	if resp.Auth.ClientToken == "" {
+		return errors.Errorf("vault auth method %s returned no token", mount)
+	}
+	c.token = resp.Auth.ClientToken
+	return nil
+}
+
+func (c *client) dataPath(secretPath string) string {
+	return strings.Trim(c.config.Mount, "/") + "/data/" + strings.Trim(secretPath, "/")
+}
+
+// do sends a request to the API and decodes the response into out. It returns
+// the response's status code along with any error.
+func (c *client) do(ctx context.Context, method string, apiPath string, in any, out any) (int, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		body = bytes.NewReader(data)
+	}
+	url := strings.TrimRight(c.config.Address, "/") + "/v1/" + apiPath
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.config.Namespace)
+	}
+
+	resp, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, errors.WithStack(err)
+	}
+
+	if out != nil && len(data) > 0 {
+		// Error responses may still have a body worth decoding, such as the
+		// metadata of deleted secrets.
+		if err := json.Unmarshal(data, out); err != nil && resp.StatusCode < 300 {
+			return resp.StatusCode, errors.Wrapf(err, "invalid response from vault for %s", apiPath)
+		}
+	}
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, apiError(resp.StatusCode, apiPath, data)
+	}
+	return resp.StatusCode, nil
+}
+
+func apiError(status int, apiPath string, body []byte) error {
+	var resp struct {
+		Errors []string `json:"errors"`
+	}
+	msg := http.StatusText(status)
+	if json.Unmarshal(body, &resp) == nil && len(resp.Errors) > 0 {
+		msg = strings.Join(resp.Errors, "; ")
+	}
+	return fmt.Errorf("vault request to %s failed (%d): %s", apiPath, status, msg)
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package vaultstore
+
+import (
+	"net/http"
+	"path"
+
+	"go.jetpack.io/envsec/pkg/envsec"
+	"go.jetpack.io/pkg/envvar"
+)
+
+const (
+	defaultMount        = "secret"
+	defaultPathPrefix   = "envsec"
+	defaultAppRoleMount = "approle"
+	defaultJWTMount     = "jwt"
+)
+
+// VaultConfig configures how to reach Vault and where to keep variables.
+// Empty fields are read from the environment when the store is initialized.
+type VaultConfig struct {
+	// Address of the Vault server. Defaults to $VAULT_ADDR.
+	Address string
+	// Namespace is the Vault Enterprise namespace. Defaults to
+	// $VAULT_NAMESPACE.
+	Namespace string
+	// Mount is where the KV v2 secrets engine is mounted. Defaults to
+	// $ENVSEC_VAULT_MOUNT, or "secret".
+	Mount string
+
+	// Token authenticates with a Vault token. Defaults to $VAULT_TOKEN.
+	Token string
+	// RoleID and SecretID authenticate with AppRole when there's no token.
+	// Default to $ENVSEC_VAULT_ROLE_ID and $ENVSEC_VAULT_SECRET_ID.
+	RoleID   string
+	SecretID string
+	// AppRoleMount is where the AppRole auth method is mounted. Defaults to
+	// "approle".
+	AppRoleMount string
+	// JWTRole authenticates with the JWT auth method, using the ID token of
+	// the user's envsec session, when there's neither a token nor an AppRole.
+	// Defaults to $ENVSEC_VAULT_JWT_ROLE.
+	JWTRole string
+	// JWTMount is where the JWT auth method is mounted. Defaults to "jwt".
+	JWTMount string
+
+	// HTTPClient is used for all requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// SecretPathFn, if set, returns the path, relative to the mount, of the
+	// secret that holds the variables of an environment.
+	SecretPathFn func(envID envsec.EnvID) string
+}
+
+// secretPath returns the path, relative to the mount, of the KV secret that
+// holds all the variables of an environment, one per key.
+func (c *VaultConfig) secretPath(envID envsec.EnvID) string {
+	if c.SecretPathFn != nil {
+		return c.SecretPathFn(envID)
+	}
+	return path.Join(
+		defaultPathPrefix,
+		envID.OrgID,
+		envID.ProjectID,
+		envID.EnvName,
+	)
+}
+
+func (c *VaultConfig) setDefaults() {
+	def := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	def(&c.Address, envvar.Get("VAULT_ADDR", ""))
+	def(&c.Namespace, envvar.Get("VAULT_NAMESPACE", ""))
+	def(&c.Mount, envvar.Get("ENVSEC_VAULT_MOUNT", defaultMount))
+	def(&c.Token, envvar.Get("VAULT_TOKEN", ""))
+	def(&c.RoleID, envvar.Get("ENVSEC_VAULT_ROLE_ID", ""))
+	def(&c.SecretID, envvar.Get("ENVSEC_VAULT_SECRET_ID", ""))
+	def(&c.AppRoleMount, defaultAppRoleMount)
+	def(&c.JWTRole, envvar.Get("ENVSEC_VAULT_JWT_ROLE", ""))
+	def(&c.JWTMount, defaultJWTMount)
+	if c.HTTPClient == nil {
+		c.HTTPClient = http.DefaultClient
+	}
+}
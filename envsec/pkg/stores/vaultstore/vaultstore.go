@@ -0,0 +1,318 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package vaultstore implements an envsec store backed by the KV v2 secrets
+// engine of HashiCorp Vault.
+//
+// All the variables of an environment are kept as the keys of a single
+// secret, so that every change is a new version of the secret and can be
+// audited and rolled back with Vault's own tools.
+package vaultstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/envsec/pkg/envsec"
+	"go.jetpack.io/pkg/auth/session"
+)
+
+// maxCASRetries is how many times a write is retried when another writer
+// changed the secret between our read and our write.
+const maxCASRetries = 3
+
+type VaultStore struct {
+	Config VaultConfig
+
+	client *client
+}
+
+// VaultStore implements interfaces Store, Versioned, Timestamped and MetadataStore (compile-time check)
+var (
+	_ envsec.Store         = (*VaultStore)(nil)
+	_ envsec.Versioned     = (*VaultStore)(nil)
+	_ envsec.Timestamped   = (*VaultStore)(nil)
+	_ envsec.MetadataStore = (*VaultStore)(nil)
+)
+
+// InitForUser authenticates with Vault. It never returns a session token:
+// even with JWT auth, variables are namespaced by the project and org IDs
+// given to envsec rather than the ones of the user's Jetify account.
+func (v *VaultStore) InitForUser(ctx context.Context, e *envsec.Envsec) (*session.Token, error) {
+	v.Config.setDefaults()
+	if v.Config.Address == "" {
+		return nil, errors.New("vault address not set. Set VAULT_ADDR to the address of your vault server")
+	}
+	v.client = &client{config: &v.Config}
+
+	switch {
+	case v.Config.Token != "":
+		v.client.token = v.Config.Token
+	case v.Config.RoleID != "":
+		err := v.client.login(ctx, v.Config.AppRoleMount, map[string]string{
+			"role_id":   v.Config.RoleID,
+			"secret_id": v.Config.SecretID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	case v.Config.JWTRole != "":
+		authClient, err := e.AuthClient()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		tok, err := authClient.LoginFlowIfNeeded(ctx)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		err = v.client.login(ctx, v.Config.JWTMount, map[string]string{
+			"role": v.Config.JWTRole,
+			"jwt":  tok.IDToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New(
+			"no vault credentials found. Set VAULT_TOKEN, ENVSEC_VAULT_ROLE_ID and " +
+				"ENVSEC_VAULT_SECRET_ID, or ENVSEC_VAULT_JWT_ROLE",
+		)
+	}
+	return nil, nil
+}
+
+func (v *VaultStore) List(ctx context.Context, envID envsec.EnvID) ([]envsec.EnvVar, error) {
+	secret, err := v.client.readSecret(ctx, v.Config.secretPath(envID))
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := envsec.DecodeMetadata(secret.Data[envsec.MetadataVarName])
+	if err != nil {
+		return nil, err
+	}
+	result := []envsec.EnvVar{}
+	for name, value := range secret.Data {
+		if name == envsec.MetadataVarName {
+			continue
+		}
+		result = append(result, envsec.EnvVar{Name: name, Value: value, EnvVarMetadata: metadata[name]})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func (v *VaultStore) Set(ctx context.Context, envID envsec.EnvID, name string, value string) error {
+	return v.SetAll(ctx, envID, map[string]string{name: value})
+}
+
+// SetAll sets all the values in a single new version of the secret.
+func (v *VaultStore) SetAll(ctx context.Context, envID envsec.EnvID, values map[string]string) error {
+	return v.update(ctx, envID, func(data map[string]string) error {
+		for name, value := range values {
+			data[name] = value
+		}
+		return nil
+	})
+}
+
+// SetAllWithMetadata sets the values in a single new version of the secret,
+// which keeps the metadata of all variables under envsec.MetadataVarName.
+func (v *VaultStore) SetAllWithMetadata(ctx context.Context, envID envsec.EnvID, vars []envsec.EnvVar) error {
+	return v.update(ctx, envID, func(data map[string]string) error {
+		metadata, err := envsec.DecodeMetadata(data[envsec.MetadataVarName])
+		if err != nil {
+			return err
+		}
+		for _, envVar := range vars {
+			data[envVar.Name] = envVar.Value
+			metadata[envVar.Name] = envVar.EnvVarMetadata
+		}
+		return setMetadata(data, metadata)
+	})
+}
+
+func (v *VaultStore) Get(ctx context.Context, envID envsec.EnvID, name string) (string, error) {
+	secret, err := v.client.readSecret(ctx, v.Config.secretPath(envID))
+	if err != nil {
+		return "", err
+	}
+	return secret.Data[name], nil
+}
+
+func (v *VaultStore) GetAll(ctx context.Context, envID envsec.EnvID, names []string) ([]envsec.EnvVar, error) {
+	secret, err := v.client.readSecret(ctx, v.Config.secretPath(envID))
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := envsec.DecodeMetadata(secret.Data[envsec.MetadataVarName])
+	if err != nil {
+		return nil, err
+	}
+	result := []envsec.EnvVar{}
+	for _, name := range names {
+		if value, ok := secret.Data[name]; ok {
+			result = append(result, envsec.EnvVar{Name: name, Value: value, EnvVarMetadata: metadata[name]})
+		}
+	}
+	return result, nil
+}
+
+func (v *VaultStore) Delete(ctx context.Context, envID envsec.EnvID, name string) error {
+	return v.DeleteAll(ctx, envID, []string{name})
+}
+
+// DeleteAll removes all the names in a single new version of the secret.
+func (v *VaultStore) DeleteAll(ctx context.Context, envID envsec.EnvID, names []string) error {
+	return v.update(ctx, envID, func(data map[string]string) error {
+		metadata, err := envsec.DecodeMetadata(data[envsec.MetadataVarName])
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			delete(data, name)
+			delete(metadata, name)
+		}
+		return setMetadata(data, metadata)
+	})
+}
+
+// History returns the versions of the environment's secret in which the
+// variable changed. Versions that were deleted or destroyed in Vault are
+// skipped, and Vault only keeps as many versions as the mount's
+// max_versions setting allows. Vault doesn't record who wrote a version, so
+// versions have no author.
+func (v *VaultStore) History(
+	ctx context.Context,
+	envID envsec.EnvID,
+	name string,
+) ([]envsec.EnvVarVersion, error) {
+	secretPath := v.Config.secretPath(envID)
+	metadata, err := v.client.readVersions(ctx, secretPath)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int, 0, len(metadata))
+	for version, m := range metadata {
+		if !m.Destroyed && m.DeletionTime == "" {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+
+	result := []envsec.EnvVarVersion{}
+	var prev *envsec.EnvVarVersion
+	for _, version := range versions {
+		secret, err := v.client.readSecretVersion(ctx, secretPath, version)
+		if err != nil {
+			return nil, err
+		}
+		value, ok := secret.Data[name]
+		if prev == nil && !ok {
+			// Not set yet.
+			continue
+		}
+		if prev != nil && prev.Deleted == !ok && prev.Value == value {
+			// Another variable changed.
+			continue
+		}
+		result = append(result, envsec.EnvVarVersion{
+			Version:   version,
+			Value:     value,
+			Deleted:   !ok,
+			Timestamp: metadata[version].CreatedTime,
+		})
+		prev = &result[len(result)-1]
+	}
+	return result, nil
+}
+
+// LastModified returns when each variable last changed, by comparing the
+// versions of the environment's secret. Like History, it needs a request
+// per version, and variables that haven't changed since the oldest version
+// Vault keeps get the time of that version.
+func (v *VaultStore) LastModified(ctx context.Context, envID envsec.EnvID) (map[string]time.Time, error) {
+	secretPath := v.Config.secretPath(envID)
+	metadata, err := v.client.readVersions(ctx, secretPath)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int, 0, len(metadata))
+	for version, m := range metadata {
+		if !m.Destroyed && m.DeletionTime == "" {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+
+	result := map[string]time.Time{}
+	prev := map[string]string{}
+	for _, version := range versions {
+		secret, err := v.client.readSecretVersion(ctx, secretPath, version)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range secret.Data {
+			if name == envsec.MetadataVarName {
+				continue
+			}
+			if old, ok := prev[name]; !ok || old != value {
+				result[name] = metadata[version].CreatedTime
+			}
+		}
+		for name := range result {
+			if _, ok := secret.Data[name]; !ok {
+				delete(result, name)
+			}
+		}
+		prev = secret.Data
+	}
+	return result, nil
+}
+
+// update writes a new version of the environment's secret with the changes
+// made by fn. Writes use check-and-set, so concurrent changes are never
+// lost: if the secret changed since it was read, the update is retried.
+func (v *VaultStore) update(
+	ctx context.Context,
+	envID envsec.EnvID,
+	fn func(data map[string]string) error,
+) error {
+	secretPath := v.Config.secretPath(envID)
+	for attempt := 0; ; attempt++ {
+		secret, err := v.client.readSecret(ctx, secretPath)
+		if err != nil {
+			return err
+		}
+		if err := fn(secret.Data); err != nil {
+			return err
+		}
+		err = v.client.writeSecret(ctx, secretPath, secret.Data, secret.Version)
+		if !errors.Is(err, errCASMismatch) || attempt == maxCASRetries {
+			return err
+		}
+	}
+}
+
+// setMetadata stores the metadata of the variables of a secret's data under
+// envsec.MetadataVarName, leaving out variables without metadata.
+func setMetadata(data map[string]string, metadata map[string]envsec.EnvVarMetadata) error {
+	for name, m := range metadata {
+		if m.IsZero() {
+			delete(metadata, name)
+		}
+	}
+	if len(metadata) == 0 {
+		delete(data, envsec.MetadataVarName)
+		return nil
+	}
+	encoded, err := envsec.EncodeMetadata(metadata)
+	if err != nil {
+		return err
+	}
+	data[envsec.MetadataVarName] = encoded
+	return nil
+}
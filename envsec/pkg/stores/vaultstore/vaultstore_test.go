@@ -0,0 +1,333 @@
+package vaultstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.jetpack.io/envsec/pkg/envsec"
+)
+
+// fakeVault implements just enough of Vault's KV v2 and AppRole APIs for the
+// store.
+type fakeVault struct {
+	mu       sync.Mutex
+	token    string
+	versions map[string][]map[string]string
+	// beforeWrite, if set, is called before every write is applied.
+	beforeWrite func()
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	// Don't pick up credentials from the environment.
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("VAULT_NAMESPACE", "")
+	fake := &fakeVault{
+		token:    "root",
+		versions: map[string][]map[string]string{},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reply := func(status int, body any) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	}
+	fail := func(status int, msg string) {
+		reply(status, map[string]any{"errors": []string{msg}})
+	}
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			fail(http.StatusBadRequest, "invalid role or secret ID")
+			return
+		}
+		reply(http.StatusOK, map[string]any{"auth": map[string]any{"client_token": f.token}})
+		return
+	}
+	if r.Header.Get("X-Vault-Token") != f.token {
+		fail(http.StatusForbidden, "permission denied")
+		return
+	}
+	if mount, ok := strings.CutPrefix(r.URL.Path, "/v1/sys/internal/ui/mounts/"); ok {
+		switch mount {
+		case "secret":
+			reply(http.StatusOK, map[string]any{"data": map[string]any{
+				"type": "kv", "path": "secret/", "options": map[string]any{"version": "2"},
+			}})
+		case "kv1":
+			reply(http.StatusOK, map[string]any{"data": map[string]any{
+				"type": "kv", "path": "kv1/", "options": map[string]any{"version": "1"},
+			}})
+		default:
+			fail(http.StatusBadRequest, "preflight capability check returned 403")
+		}
+		return
+	}
+	if path, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/metadata/"); ok {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if len(f.versions[path]) == 0 {
+			reply(http.StatusNotFound, map[string]any{"errors": []string{}})
+			return
+		}
+		versions := map[string]any{}
+		for i := range f.versions[path] {
+			versions[strconv.Itoa(i+1)] = map[string]any{
+				"created_time":  "2024-06-01T12:00:00Z",
+				"deletion_time": "",
+				"destroyed":     false,
+			}
+		}
+		reply(http.StatusOK, map[string]any{"data": map[string]any{"versions": versions}})
+		return
+	}
+	path, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/data/")
+	if strings.HasPrefix(r.URL.Path, "/v1/kv1/") {
+		// A KV v1 mount reads <mount>/data/<path> as a secret that doesn't
+		// exist.
+		reply(http.StatusNotFound, map[string]any{"errors": []string{}})
+		return
+	} else if !ok {
+		fail(http.StatusNotFound, "no handler for route")
+		return
+	}
+
+	if r.Method == http.MethodPost && f.beforeWrite != nil {
+		f.beforeWrite()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	versions := f.versions[path]
+	switch r.Method {
+	case http.MethodGet:
+		version := len(versions)
+		if v := r.URL.Query().Get("version"); v != "" {
+			version, _ = strconv.Atoi(v)
+		}
+		if version == 0 || version > len(versions) {
+			reply(http.StatusNotFound, map[string]any{"errors": []string{}})
+			return
+		}
+		reply(http.StatusOK, map[string]any{"data": map[string]any{
+			"data":     versions[version-1],
+			"metadata": map[string]any{"version": version},
+		}})
+	case http.MethodPost:
+		var body struct {
+			Data    map[string]string `json:"data"`
+			Options struct {
+				CAS *int `json:"cas"`
+			} `json:"options"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.Options.CAS != nil && *body.Options.CAS != len(versions) {
+			fail(http.StatusBadRequest, "check-and-set parameter did not match the current version")
+			return
+		}
+		f.versions[path] = append(versions, body.Data)
+		reply(http.StatusOK, map[string]any{"data": map[string]any{"version": len(versions) + 1}})
+	}
+}
+
+func (f *fakeVault) write(path string, data map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.versions[path] = append(f.versions[path], data)
+}
+
+func newStore(t *testing.T, server *httptest.Server, config VaultConfig) *VaultStore {
+	t.Helper()
+	config.Address = server.URL
+	store := &VaultStore{Config: config}
+	if _, err := store.InitForUser(context.Background(), &envsec.Envsec{}); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+var envID = envsec.EnvID{OrgID: "org", ProjectID: "proj", EnvName: "dev"}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	fake, server := newFakeVault(t)
+	store := newStore(t, server, VaultConfig{Token: "root"})
+
+	if err := store.SetAll(ctx, envID, map[string]string{"A": "1", "B": "2", "C": "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteAll(ctx, envID, []string{"B", "C"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(ctx, envID, "D", "4"); err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := store.List(ctx, envID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []envsec.EnvVar{{Name: "A", Value: "1"}, {Name: "D", Value: "4"}}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, but got %v", expected, vars)
+	}
+	if value, err := store.Get(ctx, envID, "B"); err != nil || value != "" {
+		t.Errorf("Expected an empty value, but got %q (%v)", value, err)
+	}
+
+	// Every batch is a single version.
+	if versions := len(fake.versions["envsec/org/proj/dev"]); versions != 3 {
+		t.Errorf("Expected 3 versions, but got %d", versions)
+	}
+}
+
+func TestAppRole(t *testing.T) {
+	ctx := context.Background()
+	fake, server := newFakeVault(t)
+	fake.write("envsec/org/proj/dev", map[string]string{"A": "1"})
+	store := newStore(t, server, VaultConfig{RoleID: "role", SecretID: "secret"})
+
+	if value, err := store.Get(ctx, envID, "A"); err != nil || value != "1" {
+		t.Errorf("Expected 1, but got %q (%v)", value, err)
+	}
+
+	store = &VaultStore{Config: VaultConfig{Address: server.URL, RoleID: "role", SecretID: "wrong"}}
+	if _, err := store.InitForUser(ctx, &envsec.Envsec{}); err == nil {
+		t.Error("Expected login with the wrong secret ID to fail")
+	}
+}
+
+func TestConcurrentWrite(t *testing.T) {
+	ctx := context.Background()
+	fake, server := newFakeVault(t)
+	store := newStore(t, server, VaultConfig{Token: "root"})
+
+	// Another writer sneaks in between our read and our write, once.
+	var once sync.Once
+	fake.beforeWrite = func() {
+		once.Do(func() { fake.write("envsec/org/proj/dev", map[string]string{"OTHER": "x"}) })
+	}
+	if err := store.Set(ctx, envID, "A", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := store.List(ctx, envID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []envsec.EnvVar{{Name: "A", Value: "1"}, {Name: "OTHER", Value: "x"}}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, but got %v", expected, vars)
+	}
+}
+
+func TestPermissionDenied(t *testing.T) {
+	_, server := newFakeVault(t)
+	store := newStore(t, server, VaultConfig{Token: "wrong"})
+	_, err := store.List(context.Background(), envID)
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected a permission denied error, but got %v", err)
+	}
+}
+
+func TestWrongMount(t *testing.T) {
+	for _, mount := range []string{"kv1", "typo"} {
+		t.Run(mount, func(t *testing.T) {
+			fake, server := newFakeVault(t)
+			store := newStore(t, server, VaultConfig{Token: "root", Mount: mount})
+
+			_, err := store.List(context.Background(), envID)
+			if err == nil || !strings.Contains(err.Error(), mount+" isn't a KV version 2") {
+				t.Errorf("Expected an error naming the mount, but got %v", err)
+			}
+			err = store.Set(context.Background(), envID, "A", "1")
+			if err == nil {
+				t.Error("Expected writing to the wrong mount to fail")
+			}
+			if len(fake.versions) != 0 {
+				t.Errorf("Expected nothing to be written, but got %v", fake.versions)
+			}
+		})
+	}
+}
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	fake, server := newFakeVault(t)
+	store := newStore(t, server, VaultConfig{Token: "root"})
+
+	path := "envsec/org/proj/dev"
+	fake.write(path, map[string]string{"OTHER": "x"})
+	fake.write(path, map[string]string{"OTHER": "x", "A": "1"})
+	fake.write(path, map[string]string{"OTHER": "y", "A": "1"})
+	fake.write(path, map[string]string{"OTHER": "y"})
+	fake.write(path, map[string]string{"OTHER": "y", "A": "2"})
+
+	versions, err := store.History(ctx, envID, "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	expected := []envsec.EnvVarVersion{
+		{Version: 2, Value: "1", Timestamp: created},
+		{Version: 4, Deleted: true, Timestamp: created},
+		{Version: 5, Value: "2", Timestamp: created},
+	}
+	if !reflect.DeepEqual(versions, expected) {
+		t.Errorf("Expected %v, but got %v", expected, versions)
+	}
+
+	versions, err = store.History(ctx, envsec.EnvID{ProjectID: "none"}, "A")
+	if err != nil || len(versions) != 0 {
+		t.Errorf("Expected no versions, but got %v (%v)", versions, err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	ctx := context.Background()
+	fake, server := newFakeVault(t)
+	store := newStore(t, server, VaultConfig{Token: "root"})
+
+	metadata := envsec.EnvVarMetadata{Owner: "data-team", Tags: map[string]string{"tier": "1"}}
+	err := store.SetAllWithMetadata(ctx, envID, []envsec.EnvVar{
+		{Name: "A", Value: "1", EnvVarMetadata: metadata},
+		{Name: "B", Value: "2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := store.List(ctx, envID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []envsec.EnvVar{
+		{Name: "A", Value: "1", EnvVarMetadata: metadata},
+		{Name: "B", Value: "2"},
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, but got %v", expected, vars)
+	}
+
+	// The metadata is removed along with the last variable that has any.
+	if err := store.Delete(ctx, envID, "A"); err != nil {
+		t.Fatal(err)
+	}
+	versions := fake.versions["envsec/org/proj/dev"]
+	data := versions[len(versions)-1]
+	if _, ok := data[envsec.MetadataVarName]; ok {
+		t.Errorf("Expected no %s, but got %v", envsec.MetadataVarName, data)
+	}
+}
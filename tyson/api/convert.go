@@ -0,0 +1,20 @@
+package api
+
+import (
+	"os"
+
+	"go.jetpack.io/tyson/internal/convert"
+)
+
+// ConvertOptions configure how JSON and YAML files are converted to TSON.
+type ConvertOptions = convert.Options
+
+// Convert converts a JSON or YAML file into an equivalent TSON file, keeping
+// the order of keys and YAML comments.
+func Convert(inputPath string, opts ConvertOptions) ([]byte, error) {
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	return convert.Convert(inputPath, data, opts)
+}
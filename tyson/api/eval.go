@@ -1,21 +1,228 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/dop251/goja"
 	"go.jetpack.io/tyson/internal/interpreter"
+	"go.jetpack.io/tyson/internal/tsembed"
+)
+
+// Options configure how a file is evaluated. The zero value evaluates the
+// default export without any limits.
+type Options struct {
+	// Export is the name of the export to evaluate. Defaults to the default
+	// export.
+	Export string
+	// Args are passed to the export if it's a function, and the function's
+	// result is used instead. Args are converted to JavaScript through JSON,
+	// so they can be a map or a struct with json tags. If nil, functions are
+	// called with an empty object. Evaluation fails with ErrArgsUnused if Args
+	// is set but no function is called with it.
+	Args any
+	// Timeout is the maximum amount of time evaluation may take.
+	Timeout time.Duration
+	// MaxCallDepth is the maximum depth of the JavaScript call stack.
+	MaxCallDepth int
+	// MaxOutputSize is the maximum size, in bytes, of the resulting JSON.
+	// Converting the result stops as soon as it's exceeded.
+	MaxOutputSize int
+	// Strict makes evaluation fail with an *UnrepresentableError if the
+	// result contains a value that can't be represented in JSON, such as a
+	// function or undefined. By default such values are dropped.
+	Strict bool
+	// OnLoss, if set, is called for every value that was dropped because it
+	// can't be represented in JSON.
+	OnLoss func(Loss)
+}
+
+type (
+	// Loss describes a value that was dropped because it can't be represented
+	// in JSON.
+	Loss = tsembed.Loss
+	// UnrepresentableError is returned in strict mode when the result contains
+	// a value that can't be represented in JSON.
+	UnrepresentableError = tsembed.UnrepresentableError
+)
+
+var (
+	// ErrTimeout is returned when evaluation takes longer than Options.Timeout.
+	ErrTimeout = tsembed.ErrTimeout
+	// ErrCallDepthExceeded is returned when evaluation exceeds
+	// Options.MaxCallDepth.
+	ErrCallDepthExceeded = tsembed.ErrCallDepthExceeded
+	// ErrOutputTooLarge is returned when the resulting JSON is larger than
+	// Options.MaxOutputSize.
+	ErrOutputTooLarge = tsembed.ErrOutputTooLarge
+	// ErrArgsUnused is returned when Options.Args is set, but the export being
+	// evaluated isn't a function that could be called with it.
+	ErrArgsUnused = errors.New("args can't be used")
 )
 
 func Eval(inputPath string) ([]byte, error) {
-	v, err := interpreter.Eval(inputPath)
+	return EvalContext(context.Background(), inputPath, Options{})
+}
+
+// EvalExport evaluates the file and returns the named export as JSON.
+func EvalExport(inputPath string, name string) ([]byte, error) {
+	return EvalContext(context.Background(), inputPath, Options{Export: name})
+}
+
+// EvalWithArgs evaluates the file and calls its default export, which must be
+// a function, with args. args can be a map or a struct, which is passed to
+// the function the same way json.Marshal encodes it.
+func EvalWithArgs(inputPath string, args any) ([]byte, error) {
+	return EvalContext(context.Background(), inputPath, Options{Args: args})
+}
+
+// EvalContext evaluates the file with the given options and returns the
+// result as JSON. Evaluation is interrupted if ctx is done.
+func EvalContext(ctx context.Context, inputPath string, opts Options) ([]byte, error) {
+	bundle, err := interpreter.Compile(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	return evalBundle(ctx, bundle, opts)
+}
+
+// EvalExports evaluates the file and returns every export as JSON, keyed by
+// export name. The default export, if any, is stored under "default".
+func EvalExports(inputPath string) (map[string][]byte, error) {
+	return EvalExportsContext(context.Background(), inputPath, Options{})
+}
+
+// EvalExportsContext is like EvalExports, but with options. Options.Export is
+// ignored, Options.MaxOutputSize applies to the combined size of all exports,
+// and Options.Args can't be used because exported functions aren't called.
+func EvalExportsContext(
+	ctx context.Context,
+	inputPath string,
+	opts Options,
+) (map[string][]byte, error) {
+	bundle, err := interpreter.Compile(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	return evalExports(ctx, bundle, opts)
+}
 
+func evalExports(ctx context.Context, bundle *tsembed.Bundle, opts Options) (map[string][]byte, error) {
+	if opts.Args != nil {
+		return nil, fmt.Errorf("%w: exports aren't called when evaluating all of them", ErrArgsUnused)
+	}
+	result := map[string][]byte{}
+	err := bundle.Run(ctx, opts.limits(), func(_ *goja.Runtime, exports map[string]goja.Value) error {
+		size := 0
+		for name, v := range exports {
+			if _, ok := goja.AssertFunction(v); ok {
+				// Exported helper functions aren't part of the configuration, but
+				// strict mode reports every value that isn't exported.
+				if opts.Strict {
+					return &UnrepresentableError{Loss: Loss{Path: name, Reason: "function"}}
+				}
+				continue
+			}
+			if opts.MaxOutputSize > 0 && size >= opts.MaxOutputSize {
+				// No room left, since any JSON is at least a byte.
+				return opts.outputTooLarge()
+			}
+			b, err := marshal(v, name, opts, opts.MaxOutputSize-size)
+			if err != nil {
+				return err
+			}
+			size += len(b)
+			if err := opts.checkOutputSize(size); err != nil {
+				return err
+			}
+			result[name] = b
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return result, nil
+}
+
+func evalBundle(ctx context.Context, bundle *tsembed.Bundle, opts Options) ([]byte, error) {
+	name := opts.Export
+	if name == "" {
+		name = tsembed.DefaultExport
+	}
 
-	b, err := json.MarshalIndent(v, "", "  ")
+	var result []byte
+	err := bundle.Run(ctx, opts.limits(), func(vm *goja.Runtime, exports map[string]goja.Value) error {
+		v, err := bundle.Export(exports, name)
+		if err != nil {
+			return err
+		}
+		if _, ok := goja.AssertFunction(v); ok {
+			v, err = tsembed.Call(vm, v, opts.args())
+			if err != nil {
+				return err
+			}
+		} else if opts.Args != nil {
+			return fmt.Errorf("%w: export %q isn't a function", ErrArgsUnused, name)
+		}
+		result, err = marshal(v, "$", opts, opts.MaxOutputSize)
+		if err != nil {
+			return err
+		}
+		return opts.checkOutputSize(len(result))
+	})
 	if err != nil {
 		return nil, err
 	}
-	return b, nil
+	return result, nil
+}
+
+func (o Options) args() any {
+	if o.Args == nil {
+		return map[string]any{}
+	}
+	return o.Args
+}
+
+func (o Options) limits() tsembed.Limits {
+	return tsembed.Limits{
+		Timeout:      o.Timeout,
+		MaxCallDepth: o.MaxCallDepth,
+	}
+}
+
+func (o Options) checkOutputSize(size int) error {
+	if o.MaxOutputSize > 0 && size > o.MaxOutputSize {
+		return o.outputTooLarge()
+	}
+	return nil
+}
+
+func (o Options) outputTooLarge() error {
+	return fmt.Errorf("%w: exceeds %d bytes", ErrOutputTooLarge, o.MaxOutputSize)
+}
+
+// marshal converts v, found at the given JSON path, into indented JSON. If
+// maxSize is positive, it stops as soon as the compact JSON exceeds it.
+func marshal(v goja.Value, path string, opts Options, maxSize int) ([]byte, error) {
+	compact, err := tsembed.MarshalJSON(v, tsembed.ExportOptions{
+		Path:    path,
+		Strict:  opts.Strict,
+		OnLoss:  opts.OnLoss,
+		MaxSize: maxSize,
+	})
+	if errors.Is(err, ErrOutputTooLarge) {
+		return nil, opts.outputTooLarge()
+	} else if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := json.Indent(&b, compact, "", "  "); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
 }
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     Options
+		expected error
+	}{
+		{
+			name:     "infinite loop",
+			input:    `while (true) {}; export default {}`,
+			opts:     Options{Timeout: 50 * time.Millisecond},
+			expected: ErrTimeout,
+		},
+		{
+			name:     "infinite loop in getter",
+			input:    `export default { get loop() { while (true) {} } }`,
+			opts:     Options{Timeout: 50 * time.Millisecond},
+			expected: ErrTimeout,
+		},
+		{
+			name:     "infinite recursion",
+			input:    `function f(n: number): number { return f(n + 1) }; export default f(0)`,
+			opts:     Options{MaxCallDepth: 100},
+			expected: ErrCallDepthExceeded,
+		},
+		{
+			name:     "output too large",
+			input:    `export default { field: "x".repeat(1000) }`,
+			opts:     Options{MaxOutputSize: 100},
+			expected: ErrOutputTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "input.tson")
+			writeFile(t, path, tt.input)
+			_, err := EvalContext(context.Background(), path, tt.opts)
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+}
+
+func TestEvalCanceled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.tson")
+	writeFile(t, path, `while (true) {}; export default {}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err := EvalContext(ctx, path, Options{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestEvalWithinLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.tson")
+	writeFile(t, path, `export default { field: "value" }`)
+
+	b, err := EvalContext(context.Background(), path, Options{
+		Timeout:       time.Second,
+		MaxCallDepth:  100,
+		MaxOutputSize: 100,
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"field": "value"}`, string(b))
+}
+
+func TestEvalWithArgs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.tson")
+	writeFile(t, path, `export default (args: { env: string }) => ({ env: args.env ?? "dev" })`)
+
+	b, err := EvalWithArgs(path, map[string]any{"env": "prod"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"env": "prod"}`, string(b))
+
+	b, err = Eval(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"env": "dev"}`, string(b))
+
+	// Args that no function is called with are an error.
+	writeFile(t, path, `export default { env: "dev" }`)
+	_, err = EvalWithArgs(path, map[string]any{"env": "prod"})
+	assert.ErrorIs(t, err, ErrArgsUnused)
+	_, err = EvalExportsContext(context.Background(), path, Options{Args: map[string]any{}})
+	assert.ErrorIs(t, err, ErrArgsUnused)
+}
@@ -0,0 +1,11 @@
+package api
+
+import "go.jetpack.io/tyson/internal/format"
+
+// Format re-indents the contents of a tson file and removes trailing
+// whitespace and extra blank lines. Code within lines is left as is. The
+// filename is only used in error messages and to tell .tson files from plain
+// TypeScript.
+func Format(filename string, source []byte) ([]byte, error) {
+	return format.Source(filename, source)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+
+	"go.jetpack.io/tyson/internal/interpreter"
+	"go.jetpack.io/tyson/internal/tsembed"
+)
+
+// Program is a tson file compiled, together with everything it imports, so
+// that it can be evaluated many times without compiling it again. A Program is
+// safe for concurrent use: every evaluation runs in its own runtime.
+type Program struct {
+	bundle *tsembed.Bundle
+}
+
+// CompileOptions configure how a file is compiled.
+type CompileOptions struct {
+	// CacheDir, if set, is a directory where compiled programs are cached
+	// across processes. A cached program is reused as long as none of the
+	// files that went into it have changed.
+	CacheDir string
+}
+
+// Compile compiles the file into a Program.
+func Compile(inputPath string, opts CompileOptions) (*Program, error) {
+	var bundle *tsembed.Bundle
+	var err error
+	if opts.CacheDir != "" {
+		bundle, err = interpreter.CompileCached(inputPath, opts.CacheDir)
+	} else {
+		bundle, err = interpreter.Compile(inputPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Program{bundle: bundle}, nil
+}
+
+// Eval evaluates the program and returns the result as JSON. To evaluate the
+// same program with different inputs, export a function and pass the inputs
+// as Options.Args.
+func (p *Program) Eval(ctx context.Context, opts Options) ([]byte, error) {
+	return evalBundle(ctx, p.bundle, opts)
+}
+
+// EvalExports evaluates the program and returns every export as JSON, same as
+// EvalExportsContext.
+func (p *Program) EvalExports(ctx context.Context, opts Options) (map[string][]byte, error) {
+	return evalExports(ctx, p.bundle, opts)
+}
+
+// Inputs returns the absolute paths of every file the program was compiled
+// from.
+func (p *Program) Inputs() []string {
+	return append([]string(nil), p.bundle.Inputs...)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgramConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.tson")
+	writeFile(t, path, `
+		type Args = { name: string, replicas: number }
+		export default (args: Args) => ({ service: args.name, replicas: args.replicas * 2 })
+	`)
+	program, err := Compile(path, CompileOptions{})
+	require.NoError(t, err)
+
+	type args struct {
+		Name     string `json:"name"`
+		Replicas int    `json:"replicas"`
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b, err := program.Eval(context.Background(), Options{
+				Args: args{Name: fmt.Sprintf("service-%d", i), Replicas: i},
+			})
+			if assert.NoError(t, err) {
+				expected := fmt.Sprintf(`{"service": "service-%d", "replicas": %d}`, i, i*2)
+				assert.JSONEq(t, expected, string(b))
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestProgramCache(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	path := filepath.Join(dir, "input.tson")
+	base := filepath.Join(dir, "base.tson")
+	writeFile(t, path, `import base from "./base"; export default { ...base, replicas: 3 }`)
+	writeFile(t, base, `{ name: "service" }`)
+
+	program, err := Compile(path, CompileOptions{CacheDir: cacheDir})
+	require.NoError(t, err)
+	b, err := program.Eval(context.Background(), Options{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "service", "replicas": 3}`, string(b))
+	entries, err := os.ReadDir(cacheDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// Compiling again uses the cached program.
+	program, err = Compile(path, CompileOptions{CacheDir: cacheDir})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{path, base}, program.Inputs())
+
+	// Changing an import invalidates it.
+	writeFile(t, base, `{ name: "renamed" }`)
+	program, err = Compile(path, CompileOptions{CacheDir: cacheDir})
+	require.NoError(t, err)
+	b, err = program.Eval(context.Background(), Options{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "renamed", "replicas": 3}`, string(b))
+}
+
+func TestProgramCacheResolution(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	path := filepath.Join(dir, "input.tson")
+	writeFile(t, path, `import base from "./base"; export default { ...base, replicas: 3 }`)
+	writeFile(t, filepath.Join(dir, "base.ts"), `export default { name: "ts" }`)
+
+	program, err := Compile(path, CompileOptions{CacheDir: cacheDir})
+	require.NoError(t, err)
+	b, err := program.Eval(context.Background(), Options{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "ts", "replicas": 3}`, string(b))
+
+	// A .tson file resolves before a .ts file, so adding one next to the
+	// import changes the program even though no input changed.
+	tson := filepath.Join(dir, "base.tson")
+	writeFile(t, tson, `{ name: "tson" }`)
+	program, err = Compile(path, CompileOptions{CacheDir: cacheDir})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{path, tson}, program.Inputs())
+	b, err = program.Eval(context.Background(), Options{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "tson", "replicas": 3}`, string(b))
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"go.jetpack.io/tyson/internal/interpreter"
+	"go.jetpack.io/tyson/msgerror"
+)
+
+const defaultWatchInterval = 250 * time.Millisecond
+
+type WatchOptions struct {
+	// Options configure each evaluation.
+	Options
+	// Interval is how often the inputs are checked for changes. Defaults to
+	// 250ms.
+	Interval time.Duration
+}
+
+// Update is the result of evaluating a watched file. Exactly one of JSON and
+// Err is set.
+type Update struct {
+	JSON []byte
+	Err  error
+}
+
+// Watch evaluates the file, and then evaluates it again every time the file
+// or anything it imports changes. Each result is sent on the returned
+// channel, including errors, so that callers can report them and keep
+// watching. The channel is closed once ctx is done.
+func Watch(ctx context.Context, inputPath string, opts WatchOptions) <-chan Update {
+	if opts.Interval == 0 {
+		opts.Interval = defaultWatchInterval
+	}
+
+	updates := make(chan Update)
+	go func() {
+		defer close(updates)
+
+		// Until the first build succeeds, we only know the entrypoint and the
+		// files that errors point to.
+		inputs := []string{inputPath}
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		for {
+			// Files are stat'ed before they're read, so that changes saved
+			// during evaluation trigger another one.
+			start := time.Now()
+			snapshot := statAll(inputs)
+			var update Update
+			update, inputs = evalWatched(ctx, inputPath, opts.Options, inputs)
+			snapshot = snapshot.of(inputs, start)
+
+			select {
+			case updates <- update:
+			case <-ctx.Done():
+				return
+			}
+
+			for changed := false; !changed; {
+				select {
+				case <-ticker.C:
+					changed = snapshot.changed(statAll(inputs))
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return updates
+}
+
+// evalWatched evaluates the given export and returns its result, along with
+// the files to watch for the next change. When compiling fails, the previous
+// inputs are kept, along with the files that the errors point to, so that
+// fixing any of them triggers a new evaluation.
+func evalWatched(
+	ctx context.Context,
+	inputPath string,
+	opts Options,
+	inputs []string,
+) (Update, []string) {
+	bundle, err := interpreter.Compile(inputPath)
+	if err != nil {
+		var msgErr *msgerror.Error
+		if errors.As(err, &msgErr) {
+			inputs = append(slices.Clone(inputs), msgErr.Files()...)
+		}
+		return Update{Err: err}, inputs
+	}
+	b, err := evalBundle(ctx, bundle, opts)
+	if err != nil {
+		return Update{Err: err}, bundle.Inputs
+	}
+	return Update{JSON: b}, bundle.Inputs
+}
+
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+type snapshot map[string]fileState
+
+func statAll(paths []string) snapshot {
+	result := snapshot{}
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			abs = path
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			result[abs] = fileState{}
+			continue
+		}
+		result[abs] = fileState{
+			exists:  true,
+			modTime: info.ModTime(),
+			size:    info.Size(),
+		}
+	}
+	return result
+}
+
+// of returns a snapshot of paths that keeps the states in s. Paths that
+// aren't in s are stat'ed now, and those modified after start are recorded
+// as missing, so that they count as changed.
+func (s snapshot) of(paths []string, start time.Time) snapshot {
+	result := snapshot{}
+	for path, state := range statAll(paths) {
+		if old, ok := s[path]; ok {
+			state = old
+		} else if state.modTime.After(start) {
+			state = fileState{}
+		}
+		result[path] = state
+	}
+	return result
+}
+
+func (s snapshot) changed(other snapshot) bool {
+	if len(s) != len(other) {
+		return true
+	}
+	for path, state := range s {
+		if other[path] != state {
+			return true
+		}
+	}
+	return false
+}
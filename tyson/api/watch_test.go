@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	entrypoint := filepath.Join(dir, "main.tson")
+	imported := filepath.Join(dir, "base.tson")
+	writeFile(t, entrypoint, `import base from "./base.tson"; export default { ...base }`)
+	writeFile(t, imported, `{ replicas: 1 }`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := Watch(ctx, entrypoint, WatchOptions{Interval: 10 * time.Millisecond})
+
+	update := next(t, updates)
+	require.NoError(t, update.Err)
+	assert.JSONEq(t, `{"replicas": 1}`, string(update.JSON))
+
+	// Changing an imported file triggers a new evaluation.
+	writeFile(t, imported, `{ replicas: 12 }`)
+	update = next(t, updates)
+	require.NoError(t, update.Err)
+	assert.JSONEq(t, `{"replicas": 12}`, string(update.JSON))
+
+	// Errors are delivered, and watching continues.
+	writeFile(t, imported, `{ replicas: }`)
+	update = next(t, updates)
+	assert.Error(t, update.Err)
+
+	writeFile(t, imported, `{ replicas: 3 }`)
+	update = next(t, updates)
+	require.NoError(t, update.Err)
+	assert.JSONEq(t, `{"replicas": 3}`, string(update.JSON))
+
+	cancel()
+	for range updates {
+		// Drain until the channel is closed.
+	}
+}
+
+func TestWatchBrokenImport(t *testing.T) {
+	dir := t.TempDir()
+	entrypoint := filepath.Join(dir, "main.tson")
+	imported := filepath.Join(dir, "base.tson")
+	writeFile(t, entrypoint, `import base from "./base.tson"; export default { ...base }`)
+	writeFile(t, imported, `{ replicas: }`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := Watch(ctx, entrypoint, WatchOptions{Interval: 10 * time.Millisecond})
+
+	update := next(t, updates)
+	assert.Error(t, update.Err)
+
+	// The first build failed, but fixing the import still triggers a new
+	// evaluation.
+	writeFile(t, imported, `{ replicas: 2 }`)
+	update = next(t, updates)
+	require.NoError(t, update.Err)
+	assert.JSONEq(t, `{"replicas": 2}`, string(update.JSON))
+}
+
+func TestSnapshotOf(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.tson")
+	added := filepath.Join(dir, "added.tson")
+	writeFile(t, old, `{}`)
+	s := statAll([]string{old})
+
+	// A file first read during evaluation, but changed after it started,
+	// counts as changed.
+	start := time.Now().Add(-time.Second)
+	writeFile(t, added, `{}`)
+	s = s.of([]string{old, added}, start)
+	assert.False(t, s[added].exists)
+	assert.True(t, s.changed(statAll([]string{old, added})))
+
+	// Files that are no longer inputs are forgotten.
+	s = s.of([]string{added}, time.Now())
+	assert.Len(t, s, 1)
+}
+
+func writeFile(t *testing.T, path string, contents string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+}
+
+func next(t *testing.T, updates <-chan Update) Update {
+	t.Helper()
+	select {
+	case update := <-updates:
+		return update
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for update")
+		return Update{}
+	}
+}
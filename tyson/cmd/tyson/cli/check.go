@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.jetpack.io/tyson"
+	"go.jetpack.io/tyson/api"
+)
+
+type checkCmdFlags struct {
+	jobs    int
+	strict  bool
+	timeout time.Duration
+}
+
+func CheckCmd() *cobra.Command {
+	flags := &checkCmdFlags{}
+	command := &cobra.Command{
+		Use:   "check [path ...]",
+		Short: "Evaluates tson files and reports every error, without printing the results",
+		Long: "Evaluates tson files and reports every error, without printing the results. " +
+			"Directories are searched recursively for .tson files. Exits with a nonzero " +
+			"status if any file fails to evaluate, which makes it useful in pre-commit " +
+			"hooks and CI.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCheckCmd(cmd, args, flags)
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+
+	command.Flags().IntVarP(
+		&flags.jobs, "jobs", "j", runtime.NumCPU(), "number of files to evaluate in parallel")
+	command.Flags().BoolVar(
+		&flags.strict, "strict", false, "fail if a result contains values that can't be represented in JSON")
+	command.Flags().DurationVar(
+		&flags.timeout, "timeout", 0, "maximum time evaluating each file may take, e.g. 10s (default no limit)")
+
+	return command
+}
+
+func runCheckCmd(cmd *cobra.Command, args []string, flags *checkCmdFlags) error {
+	files, err := findFiles(pathsOrCurrentDir(args))
+	if err != nil {
+		return err
+	}
+
+	opts := api.Options{Strict: flags.strict, Timeout: flags.timeout}
+	errs := forEachFile(cmd.Context(), files, flags.jobs, func(ctx context.Context, path string) error {
+		_, err := tyson.EvalExportsContext(ctx, path, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	})
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+			printError(cmd.ErrOrStderr(), err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to evaluate", failed, len(files))
+	}
+	return nil
+}
+
+// pathsOrCurrentDir returns the paths given as arguments, or the current
+// directory if there are none.
+func pathsOrCurrentDir(args []string) []string {
+	if len(args) == 0 {
+		return []string{"."}
+	}
+	return args
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"go.jetpack.io/tyson"
+	"go.jetpack.io/tyson/api"
+)
+
+type convertCmdFlags struct {
+	typeName string
+}
+
+func ConvertCmd() *cobra.Command {
+	flags := &convertCmdFlags{}
+	command := &cobra.Command{
+		Use:   "convert <file.json|file.yaml>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Converts a JSON or YAML file to tson and prints the result to stdout",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := tyson.Convert(args[0], api.ConvertOptions{TypeName: flags.typeName})
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(result)
+			return err
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+
+	command.Flags().StringVar(
+		&flags.typeName, "type", "", "generate a TypeScript type with this name, and check the result against it")
+
+	return command
+}
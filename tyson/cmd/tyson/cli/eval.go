@@ -1,56 +1,288 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
-	"github.com/hokaccha/go-prettyjson"
 	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 	"go.jetpack.io/tyson"
+	"go.jetpack.io/tyson/api"
 )
 
+type evalCmdFlags struct {
+	args     []string
+	argsJSON []string
+	export   string
+	outDir   string
+	output   string
+	strict   bool
+	timeout  time.Duration
+	watch    bool
+}
+
 func EvalCmd() *cobra.Command {
+	flags := &evalCmdFlags{}
 	command := &cobra.Command{
-		Use:           "eval <file.tson>",
-		Args:          cobra.ExactArgs(1),
-		Short:         "Evaluates a tson file and prints the result to stdout",
-		RunE:          runCmd,
+		Use:   "eval <file.tson>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Evaluates a tson file and prints the result to stdout",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCmd(cmd, args, flags)
+		},
 		SilenceErrors: true,
 		SilenceUsage:  true,
 	}
 
+	command.Flags().StringArrayVar(
+		&flags.args, "arg", nil, "pass key=value to the default export, if it's a function (can be repeated)")
+	command.Flags().StringArrayVar(
+		&flags.argsJSON, "arg-json", nil, "like --arg, but the value is parsed as JSON, e.g. replicas=3")
+	command.Flags().StringVar(
+		&flags.export, "export", "", "name of the export to evaluate instead of the default export")
+	command.Flags().StringVar(
+		&flags.outDir, "out-dir", "", "write every export to <out-dir>/<export>.json instead of printing")
+	command.Flags().StringVarP(
+		&flags.output, "output", "o", "", "write the result to a file instead of printing it")
+	command.Flags().BoolVar(
+		&flags.strict, "strict", false, "fail if the result contains values that can't be represented in JSON")
+	command.Flags().DurationVar(
+		&flags.timeout, "timeout", 0, "maximum time evaluation may take, e.g. 10s (default no limit)")
+	command.Flags().BoolVarP(
+		&flags.watch, "watch", "w", false, "re-evaluate every time the file or one of its imports changes")
+	command.MarkFlagsMutuallyExclusive("export", "out-dir")
+	command.MarkFlagsMutuallyExclusive("output", "out-dir")
+	command.MarkFlagsMutuallyExclusive("watch", "out-dir")
+	command.MarkFlagsMutuallyExclusive("arg", "out-dir")
+	command.MarkFlagsMutuallyExclusive("arg-json", "out-dir")
+
 	return command
 }
 
-func runCmd(cmd *cobra.Command, args []string) error {
+func runCmd(cmd *cobra.Command, args []string, flags *evalCmdFlags) error {
 	inputPath := args[0]
-	bytes, err := tyson.Eval(inputPath)
+	opts, err := flags.options(cmd)
 	if err != nil {
 		return err
 	}
+	if flags.watch {
+		return watch(cmd, inputPath, flags.output, opts)
+	}
+	if flags.outDir != "" {
+		return writeExports(cmd, inputPath, flags.outDir, opts)
+	}
 
-	err = printJSON(bytes)
+	bytes, err := tyson.EvalContext(cmd.Context(), inputPath, opts)
 	if err != nil {
 		return err
 	}
+
+	return writeOutput(bytes, flags.output)
+}
+
+// watch re-evaluates inputPath every time it changes, until interrupted.
+// Errors are printed, and watching continues.
+func watch(cmd *cobra.Command, inputPath string, output string, opts api.Options) error {
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	updates := tyson.Watch(ctx, inputPath, api.WatchOptions{Options: opts})
+	for update := range updates {
+		if update.Err != nil {
+			printError(cmd.ErrOrStderr(), update.Err)
+			continue
+		}
+		if err := writeOutput(update.JSON, output); err != nil {
+			printError(cmd.ErrOrStderr(), err)
+			continue
+		}
+		if output != "" {
+			fmt.Fprintf(cmd.ErrOrStderr(), "[WATCH] wrote %s\n", output)
+		}
+	}
 	return nil
 }
 
-func printJSON(bytes []byte) error {
-	if !isTerminal() {
-		color.NoColor = true
+// writeOutput writes the JSON to path, or prints it to stdout if path is empty.
+func writeOutput(bytes []byte, path string) error {
+	if path == "" {
+		return printJSON(bytes)
 	}
-	output, err := prettyjson.Format(bytes)
+	return writeFileAtomic(path, append(bytes, '\n'))
+}
+
+func (f *evalCmdFlags) options(cmd *cobra.Command) (api.Options, error) {
+	args, err := f.parseArgs()
+	if err != nil {
+		return api.Options{}, err
+	}
+	opts := api.Options{
+		Export:  f.export,
+		Strict:  f.strict,
+		Timeout: f.timeout,
+		OnLoss: func(loss api.Loss) {
+			fmt.Fprintf(cmd.ErrOrStderr(), "[WARN] dropped value that can't be represented in JSON: %s\n", loss)
+		},
+	}
+	// A nil map would still be a non-nil Args.
+	if args != nil {
+		opts.Args = args
+	}
+	return opts, nil
+}
+
+// parseArgs combines --arg and --arg-json into the object that's passed to
+// exported functions. It returns nil if neither is given.
+func (f *evalCmdFlags) parseArgs() (map[string]any, error) {
+	if len(f.args) == 0 && len(f.argsJSON) == 0 {
+		return nil, nil
+	}
+	args := map[string]any{}
+	for _, arg := range f.args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --arg %q, expected key=value", arg)
+		}
+		args[key] = value
+	}
+	for _, arg := range f.argsJSON {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --arg-json %q, expected key=json", arg)
+		}
+		var parsed any
+		if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+			return nil, fmt.Errorf("invalid JSON in --arg-json %q: %w", arg, err)
+		}
+		args[key] = parsed
+	}
+	return args, nil
+}
+
+// writeExports evaluates every export in inputPath and writes each one to its
+// own JSON file in the output directory.
+func writeExports(cmd *cobra.Command, inputPath string, outDir string, opts api.Options) error {
+	exports, err := tyson.EvalExportsContext(cmd.Context(), inputPath, opts)
 	if err != nil {
 		return err
 	}
+	if len(exports) == 0 {
+		return fmt.Errorf("%s has no exports", inputPath)
+	}
+
+	for name := range exports {
+		// Newer targets allow any string as a name, e.g. export { x as "../x" },
+		// and names must not escape the output directory.
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf("export %q can't be used as a file name", name)
+		}
+	}
 
-	fmt.Println(string(output))
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+	for name, bytes := range exports {
+		path := filepath.Join(outDir, name+".json")
+		if err := writeFileAtomic(path, append(bytes, '\n')); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// printJSON prints the JSON exactly as it's written to files, with colors on
+// terminals.
+func printJSON(bytes []byte) error {
+	if !isTerminal() {
+		_, err := fmt.Println(string(bytes))
+		return err
+	}
+	_, err := fmt.Println(colorize(bytes))
+	return err
+}
+
+var (
+	keyColor    = color.New(color.FgBlue, color.Bold)
+	stringColor = color.New(color.FgGreen, color.Bold)
+	boolColor   = color.New(color.FgYellow, color.Bold)
+	numberColor = color.New(color.FgCyan, color.Bold)
+	nullColor   = color.New(color.FgBlack, color.Bold)
+)
+
+// colorize adds colors to the tokens of valid JSON, keeping everything else,
+// such as the order of keys and the indentation, as is.
+func colorize(data []byte) string {
+	var b strings.Builder
+	for i := 0; i < len(data); {
+		var end int
+		var c *color.Color
+		switch ch := data[i]; {
+		case ch == '"':
+			end = i + 1
+			for end < len(data) && data[end] != '"' {
+				if data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end++
+			c = stringColor
+			// A string followed by a colon is a key.
+			if end < len(data) && data[end] == ':' {
+				c = keyColor
+			}
+		case ch == 't' || ch == 'f' || ch == 'n' || ch == '-' || (ch >= '0' && ch <= '9'):
+			end = i + 1
+			for end < len(data) && !strings.ContainsRune(",:[]{} \t\r\n", rune(data[end])) {
+				end++
+			}
+			c = numberColor
+			if ch == 't' || ch == 'f' {
+				c = boolColor
+			} else if ch == 'n' {
+				c = nullColor
+			}
+		default:
+			b.WriteByte(ch)
+			i++
+			continue
+		}
+		end = min(end, len(data))
+		b.WriteString(c.Sprint(string(data[i:end])))
+		i = end
+	}
+	return b.String()
+}
+
 func isTerminal() bool {
 	return isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())
 }
+
+// writeFileAtomic writes data to a temporary file and renames it to path, so
+// that readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
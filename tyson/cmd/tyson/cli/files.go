@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// findFiles expands paths into the files to process. Directories are walked
+// recursively for .tson files, skipping node_modules and hidden directories.
+// Files that are given explicitly are always included, whatever their
+// extension.
+func findFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				name := d.Name()
+				if p != path && (name == "node_modules" || strings.HasPrefix(name, ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(p) == ".tson" {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// forEachFile calls fn for every file, with up to jobs calls running in
+// parallel. It returns the error of each call, in the same order as files.
+func forEachFile(
+	ctx context.Context,
+	files []string,
+	jobs int,
+	fn func(ctx context.Context, path string) error,
+) []error {
+	if jobs < 1 {
+		jobs = 1
+	}
+	errs := make([]error, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = fn(ctx, files[i])
+			}
+		}()
+	}
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"go.jetpack.io/tyson"
+)
+
+// errNotFormatted is returned, in check mode, for files that aren't formatted.
+var errNotFormatted = errors.New("not formatted")
+
+type fmtCmdFlags struct {
+	check bool
+	jobs  int
+}
+
+func FmtCmd() *cobra.Command {
+	flags := &fmtCmdFlags{}
+	command := &cobra.Command{
+		Use:   "fmt [path ...]",
+		Short: "Re-indents tson files in place",
+		Long: "Re-indents tson files in place, with two spaces per level of nesting, " +
+			"and removes trailing whitespace and extra blank lines. Code within lines " +
+			"isn't reflowed or normalized. Directories are searched recursively for " +
+			".tson files. With --check, files are left as is and the ones that aren't " +
+			"formatted are listed instead.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFmtCmd(cmd, args, flags)
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+
+	command.Flags().BoolVar(
+		&flags.check, "check", false, "list files that aren't formatted and exit with an error, instead of formatting them")
+	command.Flags().IntVarP(
+		&flags.jobs, "jobs", "j", runtime.NumCPU(), "number of files to format in parallel")
+
+	return command
+}
+
+func runFmtCmd(cmd *cobra.Command, args []string, flags *fmtCmdFlags) error {
+	files, err := findFiles(pathsOrCurrentDir(args))
+	if err != nil {
+		return err
+	}
+
+	errs := forEachFile(cmd.Context(), files, flags.jobs, func(_ context.Context, path string) error {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		formatted, err := tyson.Format(path, source)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(source, formatted) {
+			return nil
+		}
+		if flags.check {
+			return errNotFormatted
+		}
+		return writeFileAtomic(path, formatted)
+	})
+
+	failed, count := 0, 0
+	for i, err := range errs {
+		if errors.Is(err, errNotFormatted) {
+			count++
+			fmt.Fprintln(cmd.OutOrStdout(), files[i])
+		} else if err != nil {
+			failed++
+			printError(cmd.ErrOrStderr(), err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to format", failed, len(files))
+	}
+	if count > 0 {
+		return fmt.Errorf("%d of %d files aren't formatted, run tyson fmt to fix them", count, len(files))
+	}
+	return nil
+}
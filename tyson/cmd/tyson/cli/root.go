@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -20,7 +21,10 @@ func RootCmd() *cobra.Command {
 		SilenceErrors: true,
 		SilenceUsage:  true,
 	}
+	command.AddCommand(CheckCmd())
+	command.AddCommand(ConvertCmd())
 	command.AddCommand(EvalCmd())
+	command.AddCommand(FmtCmd())
 
 	return command
 }
@@ -30,19 +34,25 @@ func Execute(ctx context.Context, args []string) int {
 	cmd.SetArgs(args)
 	err := cmd.ExecuteContext(ctx)
 	if err != nil {
-		var msgError *msgerror.Error
-		if errors.As(err, &msgError) {
-			for _, msg := range msgError.Messages() {
-				fmt.Fprintln(os.Stderr, msg)
-			}
-		} else {
-			fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
-		}
+		printError(os.Stderr, err)
 		return 1
 	}
 	return 0
 }
 
+// printError prints err to w, using esbuild's formatting for errors that carry
+// diagnostic messages.
+func printError(w io.Writer, err error) {
+	var msgError *msgerror.Error
+	if errors.As(err, &msgError) {
+		for _, msg := range msgError.Messages() {
+			fmt.Fprintln(w, msg)
+		}
+	} else {
+		fmt.Fprintf(w, "[ERROR] %s\n", err)
+	}
+}
+
 func Main() {
 	code := Execute(context.Background(), os.Args[1:])
 	os.Exit(code)
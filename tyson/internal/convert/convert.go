@@ -0,0 +1,443 @@
+// Package convert converts JSON and YAML documents into TSON.
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.jetpack.io/tyson/internal/format"
+	"go.jetpack.io/tyson/internal/yamldoc"
+	"gopkg.in/yaml.v3"
+)
+
+// Options configure the conversion.
+type Options struct {
+	// TypeName, if set, is the name of a TypeScript type that's generated from
+	// the data, and that the result is checked against with `satisfies`.
+	TypeName string
+}
+
+const indentUnit = "  "
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// maxSafeInteger is the largest integer that a JavaScript number can
+// represent exactly.
+var maxSafeInteger = big.NewInt(1<<53 - 1)
+
+// Convert converts a JSON or YAML document into a TSON object literal, keeping
+// the order of keys and, for YAML, comments. The format is chosen based on the
+// filename's extension, and anything other than .json is parsed as YAML.
+func Convert(filename string, data []byte, opts Options) ([]byte, error) {
+	if opts.TypeName != "" && !identifierRegex.MatchString(opts.TypeName) {
+		return nil, fmt.Errorf("invalid type name %q", opts.TypeName)
+	}
+
+	var root *yaml.Node
+	var err error
+	if filepath.Ext(filename) == ".json" {
+		root, err = parseJSON(data)
+	} else {
+		root, err = yamldoc.Parse(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+
+	c := &converter{}
+	if root.HeadComment != "" {
+		// The document's comment is separated from the content by a blank line.
+		c.comments(root.HeadComment, 0)
+		c.buf.WriteByte('\n')
+	}
+	value := root
+	if root.Kind == yaml.DocumentNode {
+		if len(root.Content) == 0 {
+			return nil, fmt.Errorf("%s is empty", filename)
+		}
+		value = root.Content[0]
+		c.comments(value.HeadComment, 0)
+	}
+
+	switch {
+	case opts.TypeName != "":
+		c.buf.WriteString("type " + opts.TypeName + " = " + typeOf(value, 0) + ";\n\n")
+		c.buf.WriteString("export default ")
+		c.value(value, 0, "")
+		c.buf.WriteString(" satisfies " + opts.TypeName + ";")
+	case yamldoc.Resolve(value).Kind == yaml.MappingNode:
+		// Objects are exported implicitly.
+		c.value(value, 0, "")
+	default:
+		c.buf.WriteString("export default ")
+		c.value(value, 0, "")
+		c.buf.WriteString(";")
+	}
+	c.lineComment(value.LineComment)
+	c.buf.WriteByte('\n')
+	c.comments(value.FootComment, 0)
+	if root.Kind == yaml.DocumentNode {
+		c.comments(root.FootComment, 0)
+	}
+	if c.err != nil {
+		return nil, fmt.Errorf("converting %s: %w", filename, c.err)
+	}
+
+	// Formatting also makes sure that what we generated is valid.
+	return format.Source("output.tson", []byte(c.buf.String()))
+}
+
+// parseJSON parses JSON into the same tree of nodes as YAML. It doesn't rely
+// on the YAML parser, since not all valid JSON is valid YAML (for example,
+// JSON indented with tabs).
+func parseJSON(data []byte) (*yaml.Node, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	node, err := parseJSONValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after the top-level value")
+	}
+	return node, nil
+}
+
+func parseJSONValue(decoder *json.Decoder) (*yaml.Node, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token := token.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if token == '{' {
+			node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		for decoder.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, scalar("!!str", key.(string)))
+			}
+			value, err := parseJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+		// Consume the closing delimiter.
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return scalar("!!str", token), nil
+	case json.Number:
+		if strings.ContainsAny(token.String(), ".eE") {
+			return scalar("!!float", token.String()), nil
+		}
+		return scalar("!!int", token.String()), nil
+	case bool:
+		return scalar("!!bool", strconv.FormatBool(token)), nil
+	case nil:
+		return scalar("!!null", "null"), nil
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", token)
+}
+
+func scalar(tag string, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
+
+type converter struct {
+	buf strings.Builder
+	// err is the first value that couldn't be converted.
+	err error
+}
+
+// value writes n as a TypeScript expression. lineComment is written after the
+// opening bracket of objects and arrays that span several lines.
+func (c *converter) value(n *yaml.Node, depth int, lineComment string) {
+	n = yamldoc.Resolve(n)
+	switch n.Kind {
+	case yaml.MappingNode:
+		c.mapping(n, depth, lineComment)
+	case yaml.SequenceNode:
+		c.sequence(n, depth, lineComment)
+	default:
+		if err := checkInteger(n); err != nil && c.err == nil {
+			c.err = err
+		}
+		c.buf.WriteString(scalarValue(n))
+	}
+}
+
+func (c *converter) mapping(n *yaml.Node, depth int, lineComment string) {
+	if len(n.Content) == 0 {
+		c.buf.WriteString("{}")
+		return
+	}
+	c.buf.WriteString("{")
+	c.lineComment(lineComment)
+	c.buf.WriteByte('\n')
+	indent := strings.Repeat(indentUnit, depth+1)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		c.comments(key.HeadComment, depth+1)
+		c.comments(value.HeadComment, depth+1)
+		c.buf.WriteString(indent)
+		if key.ShortTag() == "!!merge" {
+			// YAML merge keys (<<: *base) become spreads.
+			c.merge(value, depth+1)
+		} else {
+			c.buf.WriteString(propertyName(key.Value) + ": ")
+			c.entry(value, depth+1, key.LineComment)
+		}
+		c.comments(key.FootComment, depth+1)
+		c.comments(value.FootComment, depth+1)
+	}
+	c.buf.WriteString(strings.Repeat(indentUnit, depth) + "}")
+}
+
+func (c *converter) merge(value *yaml.Node, depth int) {
+	sources := []*yaml.Node{value}
+	if value.Kind == yaml.SequenceNode {
+		sources = value.Content
+	}
+	for i, source := range sources {
+		if i > 0 {
+			c.buf.WriteString(strings.Repeat(indentUnit, depth))
+		}
+		c.buf.WriteString("...")
+		c.entry(source, depth, "")
+	}
+}
+
+func (c *converter) sequence(n *yaml.Node, depth int, lineComment string) {
+	if len(n.Content) == 0 {
+		c.buf.WriteString("[]")
+		return
+	}
+	c.buf.WriteString("[")
+	c.lineComment(lineComment)
+	c.buf.WriteByte('\n')
+	for _, item := range n.Content {
+		c.comments(item.HeadComment, depth+1)
+		c.buf.WriteString(strings.Repeat(indentUnit, depth+1))
+		c.entry(item, depth+1, "")
+		c.comments(item.FootComment, depth+1)
+	}
+	c.buf.WriteString(strings.Repeat(indentUnit, depth) + "]")
+}
+
+// entry writes a value inside an object or array, followed by a comma and its
+// line comment.
+func (c *converter) entry(value *yaml.Node, depth int, keyComment string) {
+	resolved := yamldoc.Resolve(value)
+	isBlock := (resolved.Kind == yaml.MappingNode || resolved.Kind == yaml.SequenceNode) &&
+		len(resolved.Content) > 0
+	if isBlock {
+		c.value(value, depth, joinComments(keyComment, value.LineComment))
+		c.buf.WriteString(",\n")
+		return
+	}
+	c.value(value, depth, "")
+	c.buf.WriteString(",")
+	c.lineComment(joinComments(keyComment, value.LineComment))
+	c.buf.WriteByte('\n')
+}
+
+// comments writes YAML comments, which may span several lines, as //
+// comments on their own lines.
+func (c *converter) comments(comment string, depth int) {
+	if comment == "" {
+		return
+	}
+	for _, line := range strings.Split(comment, "\n") {
+		text := commentText(line)
+		if text == "" && !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			// Blank lines separate groups of comments.
+			c.buf.WriteByte('\n')
+			continue
+		}
+		c.buf.WriteString(strings.Repeat(indentUnit, depth) + "//" + prefixSpace(text) + "\n")
+	}
+}
+
+func (c *converter) lineComment(comment string) {
+	if comment == "" {
+		return
+	}
+	lines := strings.Split(comment, "\n")
+	texts := make([]string, 0, len(lines))
+	for _, line := range lines {
+		texts = append(texts, commentText(line))
+	}
+	c.buf.WriteString(" //" + prefixSpace(strings.Join(texts, " ")))
+}
+
+func joinComments(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + "\n" + b
+}
+
+// commentText returns the text of a YAML comment line, without the #.
+func commentText(line string) string {
+	text := strings.TrimSpace(line)
+	text = strings.TrimPrefix(text, "#")
+	return strings.TrimRight(strings.TrimPrefix(text, " "), " \t")
+}
+
+func prefixSpace(text string) string {
+	if text == "" {
+		return ""
+	}
+	return " " + text
+}
+
+// propertyName returns key as an object property name, quoting it only if
+// it's not a valid identifier.
+func propertyName(key string) string {
+	if key == "__proto__" {
+		// Both __proto__: and "__proto__": set the object's prototype instead
+		// of adding a property, but computed names don't.
+		return `["__proto__"]`
+	}
+	if identifierRegex.MatchString(key) {
+		return key
+	}
+	return yamldoc.Quote(key)
+}
+
+// checkInteger returns an error if n is an integer that a JavaScript number
+// can't represent exactly, such as a 64-bit ID.
+func checkInteger(n *yaml.Node) error {
+	if n.ShortTag() != "!!int" {
+		return nil
+	}
+	i, ok := new(big.Int).SetString(strings.ReplaceAll(n.Value, "_", ""), 0)
+	if !ok {
+		// Not an integer after all, such as the sexagesimal 1:30.
+		return nil
+	}
+	if i.CmpAbs(maxSafeInteger) <= 0 {
+		return nil
+	}
+	err := fmt.Errorf("%s can't be represented exactly as a number. Quote it to keep it as a string", n.Value)
+	if n.Line > 0 {
+		// Only YAML nodes know their line.
+		err = fmt.Errorf("line %d: %w", n.Line, err)
+	}
+	return err
+}
+
+func scalarValue(n *yaml.Node) string {
+	switch n.ShortTag() {
+	case "!!null":
+		return "null"
+	case "!!bool":
+		var b bool
+		if err := n.Decode(&b); err == nil {
+			return strconv.FormatBool(b)
+		}
+	case "!!int":
+		if yamldoc.JSONNumber(n.Value) {
+			return n.Value
+		}
+		// Other notations, such as 0x1F or 1_000.
+		var i int64
+		if err := n.Decode(&i); err == nil {
+			return strconv.FormatInt(i, 10)
+		}
+	case "!!float":
+		if yamldoc.JSONNumber(n.Value) {
+			return n.Value
+		}
+		var f float64
+		if err := n.Decode(&f); err == nil {
+			switch {
+			case math.IsNaN(f):
+				return "NaN"
+			case math.IsInf(f, 1):
+				return "Infinity"
+			case math.IsInf(f, -1):
+				return "-Infinity"
+			}
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	}
+	// Strings, as well as timestamps and other values that don't have a
+	// TypeScript equivalent.
+	if strings.Contains(n.Value, "\n") && (n.Style == yaml.LiteralStyle || n.Style == yaml.FoldedStyle) {
+		return templateLiteral(n.Value)
+	}
+	return yamldoc.Quote(n.Value)
+}
+
+// templateLiteral writes a multi-line string as a template literal, which is
+// how multi-line strings are usually written in TSON.
+func templateLiteral(s string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "`", "\\`", "${", "\\${")
+	return "`" + replacer.Replace(s) + "`"
+}
+
+// typeOf returns a TypeScript type that describes n.
+func typeOf(n *yaml.Node, depth int) string {
+	n = yamldoc.Resolve(n)
+	switch n.Kind {
+	case yaml.MappingNode:
+		pairs := yamldoc.Flatten(n)
+		if len(pairs) == 0 {
+			return "Record<string, never>"
+		}
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, p := range pairs {
+			b.WriteString(strings.Repeat(indentUnit, depth+1))
+			b.WriteString(propertyName(p.Key) + ": " + typeOf(p.Value, depth+1) + ";\n")
+		}
+		b.WriteString(strings.Repeat(indentUnit, depth) + "}")
+		return b.String()
+	case yaml.SequenceNode:
+		types := []string{}
+		seen := map[string]bool{}
+		for _, item := range n.Content {
+			t := typeOf(item, depth)
+			if !seen[t] {
+				seen[t] = true
+				types = append(types, t)
+			}
+		}
+		switch len(types) {
+		case 0:
+			return "unknown[]"
+		case 1:
+			return types[0] + "[]"
+		}
+		return "(" + strings.Join(types, " | ") + ")[]"
+	}
+
+	switch n.ShortTag() {
+	case "!!null":
+		return "null"
+	case "!!bool":
+		return "boolean"
+	case "!!int", "!!float":
+		return "number"
+	}
+	return "string"
+}
@@ -0,0 +1,166 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		input    string
+		opts     Options
+		expected string
+	}{
+		{
+			name:     "json keeps key order and quotes only invalid identifiers",
+			filename: "config.json",
+			input:    "{\n\t\"zone\": \"us\",\n\t\"app-name\": \"api\",\n\t\"ports\": [80, 1.5e3],\n\t\"tags\": {}\n}",
+			expected: `{
+  zone: "us",
+  "app-name": "api",
+  ports: [
+    80,
+    1.5e3,
+  ],
+  tags: {},
+}
+`,
+		},
+		{
+			name:     "yaml comments",
+			filename: "config.yaml",
+			input: `# Service configuration
+
+# The name is used in DNS
+name: api # must be unique
+ports: # exposed ports
+  - 80
+`,
+			expected: `// Service configuration
+
+{
+  // The name is used in DNS
+  name: "api", // must be unique
+  ports: [ // exposed ports
+    80,
+  ],
+}
+`,
+		},
+		{
+			name:     "yaml scalars",
+			filename: "config.yml",
+			input: `hex: 0x1F
+ratio: .5
+enabled: true
+missing: null
+date: 2024-01-01
+script: |
+  echo ${USER}
+`,
+			expected: "{\n  hex: 31,\n  ratio: 0.5,\n  enabled: true,\n  missing: null,\n  date: \"2024-01-01\",\n" +
+				"  script: `echo \\${USER}\n`,\n}\n",
+		},
+		{
+			name:     "yaml merge keys",
+			filename: "config.yaml",
+			input: `base: &base
+  image: nginx
+prod:
+  <<: *base
+  replicas: 3
+`,
+			expected: `{
+  base: {
+    image: "nginx",
+  },
+  prod: {
+    ...{
+      image: "nginx",
+    },
+    replicas: 3,
+  },
+}
+`,
+		},
+		{
+			name:     "__proto__ is a computed name, so it stays a property",
+			filename: "config.json",
+			input:    `{"__proto__": {"admin": true}}`,
+			opts:     Options{TypeName: "Config"},
+			expected: `type Config = {
+  ["__proto__"]: {
+    admin: boolean;
+  };
+};
+
+export default {
+  ["__proto__"]: {
+    admin: true,
+  },
+} satisfies Config;
+`,
+		},
+		{
+			name:     "top-level array",
+			filename: "list.json",
+			input:    `[1, "two"]`,
+			expected: "export default [\n  1,\n  \"two\",\n];\n",
+		},
+		{
+			name:     "type annotation",
+			filename: "config.json",
+			input:    `{"name": "api", "servers": [{"host": "a", "port": 1}], "tags": []}`,
+			opts:     Options{TypeName: "Config"},
+			expected: `type Config = {
+  name: string;
+  servers: {
+    host: string;
+    port: number;
+  }[];
+  tags: unknown[];
+};
+
+export default {
+  name: "api",
+  servers: [
+    {
+      host: "a",
+      port: 1,
+    },
+  ],
+  tags: [],
+} satisfies Config;
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Convert(tt.filename, []byte(tt.input), tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(result))
+		})
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	_, err := Convert("config.json", []byte(`{"a": 1} {}`), Options{})
+	assert.ErrorContains(t, err, "unexpected data after the top-level value")
+
+	_, err = Convert("config.yaml", []byte("a: 1\n---\nb: 2\n"), Options{})
+	assert.ErrorContains(t, err, "single YAML document")
+
+	_, err = Convert("config.json", []byte(`{}`), Options{TypeName: "not valid"})
+	assert.ErrorContains(t, err, `invalid type name "not valid"`)
+
+	_, err = Convert("config.json", []byte(`{"id": 9007199254740993}`), Options{})
+	assert.ErrorContains(t, err, "9007199254740993 can't be represented exactly")
+	_, err = Convert("config.yaml", []byte("id: 0x20000000000001\n"), Options{})
+	assert.ErrorContains(t, err, "line 1: 0x20000000000001 can't be represented exactly")
+	_, err = Convert("config.yaml", []byte("id: 9007199254740991\n"), Options{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,412 @@
+// Package format re-indents tson files.
+//
+// It isn't a full formatter: it only changes whitespace at the start and end
+// of lines outside of strings, template literals and regular expressions.
+// Lines are indented with two spaces per level of nesting, trailing
+// whitespace is removed, runs of blank lines are collapsed into one and files
+// end in a single newline. Code is never reflowed, split across lines or
+// joined, so `ports: [80,\n443]` keeps its line break, and formatting can't
+// change what a file evaluates to.
+//
+// Brackets are found with a scanner that only knows enough of the syntax to
+// skip strings, comments and regular expressions. When it gets something
+// wrong, the result is checked against the original and rejected, so the
+// file is left as is with an error.
+package format
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"go.jetpack.io/tyson/internal/interpreter"
+	"go.jetpack.io/tyson/msgerror"
+)
+
+const indentUnit = "  "
+
+// Source re-indents the contents of a file. The filename is used in error
+// messages, and to decide whether the file is a .tson file. It returns an
+// error if the source has syntax errors.
+func Source(filename string, source []byte) ([]byte, error) {
+	src := strings.ReplaceAll(string(source), "\r\n", "\n")
+	before, err := minify(filename, src)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted := layout(scan(src))
+
+	// Formatting should only ever change whitespace that esbuild ignores, but
+	// double check, since a bug here would silently change configuration.
+	after, err := minify(filename, formatted)
+	if err != nil || after != before {
+		return nil, fmt.Errorf("formatting %s would change its meaning, leaving it as is", filename)
+	}
+	return []byte(formatted), nil
+}
+
+// minify compiles the source without whitespace and comments, which both
+// checks it for syntax errors and gives us something to compare to make sure
+// formatting didn't change the code.
+func minify(filename string, src string) (string, error) {
+	if filepath.Ext(filename) == ".tson" {
+		src = interpreter.TransformTSON([]byte(src))
+	}
+	result := api.Transform(src, api.TransformOptions{
+		LegalComments:    api.LegalCommentsNone,
+		Loader:           api.LoaderTS,
+		MinifyWhitespace: true,
+		Sourcefile:       filename,
+	})
+	if len(result.Errors) > 0 {
+		msg := fmt.Sprintf("%d syntax errors when formatting %s", len(result.Errors), filename)
+		return "", msgerror.ErrFromMessages(msg, result.Errors)
+	}
+	return string(result.Code), nil
+}
+
+// line is a line of source, along with what scanning it found out about it.
+type line struct {
+	text string
+	// startsInLiteral is set if the line starts inside a string or template
+	// literal, where whitespace is significant.
+	startsInLiteral bool
+	// endsInLiteral is set if the line ends inside a string or template
+	// literal, so trailing whitespace is significant.
+	endsInLiteral bool
+	// startsInComment is set if the line starts inside a block comment.
+	startsInComment bool
+	// brackets are the brackets in code on the line, in order.
+	brackets []byte
+	// leadingCloser is set if the line starts with a closing bracket.
+	leadingCloser bool
+	// code are the non-whitespace characters of code on the line. Literals are
+	// represented by their quotes.
+	code []byte
+}
+
+type mode int
+
+const (
+	modeCode mode = iota
+	modeLineComment
+	modeBlockComment
+	modeString
+	modeTemplate
+	modeRegex
+)
+
+// scan splits src into lines, tokenizing just enough to know which brackets
+// are code, and where whitespace is significant.
+func scan(src string) []*line {
+	s := &scanner{src: src, cur: &line{}}
+	for s.i = 0; s.i < len(src); s.i++ {
+		c := src[s.i]
+		if c == '\n' {
+			s.endLine()
+			continue
+		}
+
+		switch s.mode {
+		case modeCode:
+			s.scanCode(c)
+		case modeLineComment:
+		case modeBlockComment:
+			if c == '*' && s.peek() == '/' {
+				s.mode = modeCode
+				s.i++
+			}
+		case modeString:
+			if s.escaped {
+				s.escaped = false
+			} else if c == '\\' {
+				s.escaped = true
+			} else if c == s.quote {
+				s.mode = modeCode
+			}
+		case modeTemplate:
+			if s.escaped {
+				s.escaped = false
+			} else if c == '\\' {
+				s.escaped = true
+			} else if c == '`' {
+				s.mode = modeCode
+				s.significant(c)
+			} else if c == '$' && s.peek() == '{' {
+				s.braces = append(s.braces, '$')
+				s.templateExprs++
+				s.mode = modeCode
+				s.i++
+			}
+		case modeRegex:
+			if s.escaped {
+				s.escaped = false
+			} else if c == '\\' {
+				s.escaped = true
+			} else if c == '[' {
+				s.inClass = true
+			} else if c == ']' {
+				s.inClass = false
+			} else if c == '/' && !s.inClass {
+				s.mode = modeCode
+			}
+		}
+	}
+	s.cur.text = src[s.start:]
+	s.lines = append(s.lines, s.cur)
+	return s.lines
+}
+
+type scanner struct {
+	src   string
+	i     int
+	start int // offset of the current line
+	cur   *line
+	lines []*line
+
+	mode    mode
+	quote   byte
+	escaped bool
+	inClass bool
+	// braces are the open curly braces, either '{' or '$' for the start of a
+	// template literal expression.
+	braces        []byte
+	templateExprs int
+
+	// lastSignificant is the last non-whitespace character of code, and word
+	// the identifier it's part of, if any. They're used to tell regular
+	// expressions and divisions apart.
+	lastSignificant byte
+	word            string
+	lastWord        string
+}
+
+func (s *scanner) peek() byte {
+	if s.i+1 < len(s.src) {
+		return s.src[s.i+1]
+	}
+	return 0
+}
+
+func (s *scanner) inLiteral() bool {
+	return s.mode == modeString || s.mode == modeTemplate || s.templateExprs > 0
+}
+
+func (s *scanner) endLine() {
+	switch s.mode {
+	case modeLineComment, modeRegex:
+		s.mode = modeCode
+	case modeString:
+		// Only an escaped newline continues a string.
+		if !s.escaped {
+			s.mode = modeCode
+		}
+	}
+	s.escaped = false
+	s.endWord()
+
+	s.cur.text = s.src[s.start:s.i]
+	s.cur.endsInLiteral = s.inLiteral()
+	s.lines = append(s.lines, s.cur)
+	s.start = s.i + 1
+	s.cur = &line{
+		startsInLiteral: s.inLiteral(),
+		startsInComment: s.mode == modeBlockComment,
+	}
+}
+
+func (s *scanner) scanCode(c byte) {
+	switch {
+	case c == '/' && s.peek() == '/':
+		s.mode = modeLineComment
+		s.i++
+	case c == '/' && s.peek() == '*':
+		s.mode = modeBlockComment
+		s.i++
+	case c == '"' || c == '\'':
+		s.mode = modeString
+		s.quote = c
+		s.significant(c)
+	case c == '`':
+		s.mode = modeTemplate
+		s.significant(c)
+	case c == '/' && s.regexAllowed():
+		s.mode = modeRegex
+		s.inClass = false
+		s.significant(c)
+	case c == '{':
+		s.braces = append(s.braces, c)
+		s.bracket(c)
+	case c == '}':
+		if len(s.braces) > 0 && s.braces[len(s.braces)-1] == '$' {
+			// End of a template literal expression.
+			s.braces = s.braces[:len(s.braces)-1]
+			s.templateExprs--
+			s.mode = modeTemplate
+			return
+		}
+		if len(s.braces) > 0 {
+			s.braces = s.braces[:len(s.braces)-1]
+		}
+		s.bracket(c)
+	case c == '(' || c == '[' || c == ')' || c == ']':
+		s.bracket(c)
+	case c == ' ' || c == '\t' || c == '\r':
+		s.endWord()
+	default:
+		s.significant(c)
+	}
+}
+
+func (s *scanner) bracket(c byte) {
+	if s.templateExprs == 0 {
+		if isCloser(c) && len(s.cur.code) == 0 && strings.TrimSpace(s.src[s.start:s.i]) == "" {
+			s.cur.leadingCloser = true
+		}
+		s.cur.brackets = append(s.cur.brackets, c)
+	}
+	s.significant(c)
+}
+
+func (s *scanner) significant(c byte) {
+	if isIdentifierChar(c) {
+		s.word += string(c)
+	} else {
+		s.endWord()
+	}
+	s.lastSignificant = c
+	if s.templateExprs == 0 {
+		s.cur.code = append(s.cur.code, c)
+	}
+}
+
+func (s *scanner) endWord() {
+	if s.word != "" {
+		s.lastWord = s.word
+		s.word = ""
+	}
+}
+
+// keywordsBeforeRegex are the keywords after which a slash starts a regular
+// expression rather than being a division.
+var keywordsBeforeRegex = map[string]bool{
+	"await": true, "case": true, "delete": true, "do": true, "else": true,
+	"in": true, "instanceof": true, "new": true, "of": true, "return": true,
+	"throw": true, "typeof": true, "void": true, "yield": true,
+}
+
+func (s *scanner) regexAllowed() bool {
+	c := s.lastSignificant
+	if c == 0 {
+		return true
+	}
+	if isIdentifierChar(c) {
+		word := s.word
+		if word == "" {
+			word = s.lastWord
+		}
+		return keywordsBeforeRegex[word]
+	}
+	return strings.IndexByte("(,=:[!&|?{};+-*%<>~^", c) != -1
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func isCloser(c byte) bool {
+	return c == '}' || c == ')' || c == ']'
+}
+
+// layout re-indents the lines and normalizes whitespace between them.
+func layout(lines []*line) string {
+	var out strings.Builder
+	// levels holds, for every open bracket, the indentation level of the line
+	// it was opened on.
+	var levels []int
+	var prevCode []byte
+	blank := false
+
+	write := func(text string) {
+		if blank && out.Len() > 0 {
+			out.WriteByte('\n')
+		}
+		blank = false
+		out.WriteString(text)
+		out.WriteByte('\n')
+	}
+	updateLevels := func(l *line, level int) {
+		for _, c := range l.brackets {
+			if isCloser(c) {
+				if len(levels) > 0 {
+					levels = levels[:len(levels)-1]
+				}
+			} else {
+				levels = append(levels, level)
+			}
+		}
+	}
+
+	for _, l := range lines {
+		level := 0
+		if len(levels) > 0 {
+			level = levels[len(levels)-1] + 1
+		}
+
+		text := strings.TrimSpace(l.text)
+		if l.endsInLiteral {
+			text = strings.TrimLeft(l.text, " \t")
+		}
+		switch {
+		case l.startsInLiteral:
+			// Whitespace is significant, so leave the line as is.
+			write(l.text)
+		case text == "":
+			blank = true
+		case l.startsInComment:
+			if strings.HasPrefix(text, "*") {
+				// Align the stars of JSDoc style comments.
+				write(strings.Repeat(indentUnit, level) + " " + text)
+			} else {
+				write(strings.TrimRight(l.text, " \t"))
+			}
+		default:
+			if l.leadingCloser && len(levels) > 0 {
+				level = levels[len(levels)-1]
+			} else if continues(prevCode, l.code) {
+				level++
+			}
+			write(strings.Repeat(indentUnit, level) + text)
+		}
+
+		updateLevels(l, level)
+		if len(l.code) > 0 && !l.startsInLiteral {
+			prevCode = l.code
+		}
+	}
+	return out.String()
+}
+
+// continues reports whether a line continues the expression of the previous
+// line, such as the operand of a binary operator or the next method in a
+// chain, so that it should be indented one more level.
+func continues(prev []byte, code []byte) bool {
+	p, c := string(prev), string(code)
+	for _, suffix := range []string{"=", "=>", "?", ":", "&&", "||", "??", "+", "-", "*", "%", "|", "&"} {
+		if strings.HasSuffix(p, suffix) && !strings.HasSuffix(p, "++") && !strings.HasSuffix(p, "--") {
+			return true
+		}
+	}
+	if strings.HasPrefix(c, ".") {
+		return !strings.HasPrefix(c, "...")
+	}
+	for _, prefix := range []string{"?", ":", "|", "&"} {
+		if strings.HasPrefix(c, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name: "indents nested objects",
+			input: `{
+name: "service",
+    ports: [
+  80,
+      443,
+],
+	env: { debug: true,
+  level: "info" },
+}`,
+			expected: `{
+  name: "service",
+  ports: [
+    80,
+    443,
+  ],
+  env: { debug: true,
+    level: "info" },
+}
+`,
+		},
+		{
+			name:     "removes trailing whitespace and extra blank lines",
+			input:    "\n\n{\n  a: 1,   \n\n\n\n  b: 2,\n}\n\n\n",
+			expected: "{\n  a: 1,\n\n  b: 2,\n}\n",
+		},
+		{
+			name:     "keeps whitespace in template literals",
+			input:    "export default {\n    text: `\n      line 1   \n  line 2 ${[1,\n2]}\n`,\n    after: { a: 1 },\n}\n",
+			expected: "export default {\n  text: `\n      line 1   \n  line 2 ${[1,\n2]}\n`,\n  after: { a: 1 },\n}\n",
+		},
+		{
+			name:     "ignores brackets in strings, comments and regular expressions",
+			input:    "export default {\n    a: \"{[(\",\n  // {\n      b: /[}]\\//.source, /* ( */\n  c: 4 / 2,\n      d: 1,\n}\n",
+			expected: "export default {\n  a: \"{[(\",\n  // {\n  b: /[}]\\//.source, /* ( */\n  c: 4 / 2,\n  d: 1,\n}\n",
+		},
+		{
+			name: "aligns block comments",
+			input: `{
+      /**
+    * Docs
+        */
+  a: 1,
+}`,
+			expected: `{
+  /**
+   * Docs
+   */
+  a: 1,
+}
+`,
+		},
+		{
+			name: "indents continuation lines",
+			input: `const base =
+{ a: 1 };
+
+export default {
+...base,
+b: base.a > 0
+? "yes"
+: "no",
+c: [1, 2]
+.map((x) => x * 2),
+}
+`,
+			expected: `const base =
+  { a: 1 };
+
+export default {
+  ...base,
+  b: base.a > 0
+    ? "yes"
+    : "no",
+  c: [1, 2]
+    .map((x) => x * 2),
+}
+`,
+		},
+		{
+			name: "closes several brackets on one line",
+			input: `export default {
+  list: f({
+  a: [
+  1,
+  ]}),
+}`,
+			expected: `export default {
+  list: f({
+    a: [
+      1,
+    ]}),
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted, err := Source("input.tson", []byte(tt.input))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(formatted))
+
+			// Formatting is idempotent.
+			again, err := Source("input.tson", formatted)
+			assert.NoError(t, err)
+			assert.Equal(t, string(formatted), string(again))
+		})
+	}
+}
+
+func TestSourceSyntaxError(t *testing.T) {
+	_, err := Source("input.tson", []byte("{\n  a: ,\n}\n"))
+	assert.ErrorContains(t, err, "syntax errors when formatting input.tson")
+}
@@ -0,0 +1,63 @@
+package interpreter
+
+import (
+	"os"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"go.jetpack.io/tyson/internal/yamldoc"
+)
+
+// dataImport lets .tson files import .json and .yaml files as plain data:
+//
+//	import base from './base.yaml';
+var dataImport = api.Plugin{
+	Name: "dataImport",
+	Setup: func(build api.PluginBuild) {
+		build.OnLoad(
+			api.OnLoadOptions{Filter: `\.json$`},
+			loadJSON,
+		)
+		build.OnLoad(
+			api.OnLoadOptions{Filter: `\.ya?ml$`},
+			loadYAML,
+		)
+	},
+}
+
+func loadJSON(args api.OnLoadArgs) (api.OnLoadResult, error) {
+	data, err := os.ReadFile(args.Path)
+	if err != nil {
+		return api.OnLoadResult{}, err
+	}
+
+	contents := string(data)
+	return api.OnLoadResult{
+		Contents: &contents,
+		Loader:   api.LoaderJSON,
+	}, nil
+}
+
+func loadYAML(args api.OnLoadArgs) (api.OnLoadResult, error) {
+	data, err := os.ReadFile(args.Path)
+	if err != nil {
+		return api.OnLoadResult{}, err
+	}
+
+	doc, err := yamldoc.Parse(data)
+	if err != nil {
+		return api.OnLoadResult{}, err
+	}
+
+	// YAML is a superset of JSON, so we convert it to JSON and let esbuild load
+	// it with its JSON loader. The conversion keeps the order of keys.
+	converted, err := yamldoc.ToJSON(doc)
+	if err != nil {
+		return api.OnLoadResult{}, err
+	}
+
+	contents := string(converted)
+	return api.OnLoadResult{
+		Contents: &contents,
+		Loader:   api.LoaderJSON,
+	}, nil
+}
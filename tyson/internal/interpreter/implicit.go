@@ -0,0 +1,189 @@
+package interpreter
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/go-sourcemap/sourcemap"
+)
+
+const exportDefault = "export default "
+
+// maxCandidates is how many curly braces before a syntax error are tried as
+// the start of the exported object.
+const maxCandidates = 256
+
+// findImplicitExport returns the offset of the object that a .tson file
+// implicitly exports, or -1 if it doesn't implicitly export anything.
+//
+// A file implicitly exports its top-level object literal when it has no
+// explicit exports. The object may be preceded by imports, type declarations,
+// functions and other statements.
+//
+// A statement that starts with a curly brace is a block, so TypeScript
+// parsers don't read the object as an object. We let esbuild's parser find
+// the curly braces that can start it, and a brace is the exported object if,
+// after adding `export default` in front of it, esbuild says that the file's
+// only export is the default one. If more than one brace works, the file is
+// ambiguous and nothing is exported.
+func findImplicitExport(data []byte) int {
+	src := string(data)
+	transformed := transform(src, api.SourceMapExternal)
+
+	var candidates []int
+	if len(transformed.Errors) == 0 {
+		// The object parsed as a block statement, such as { a: 1 }.
+		if exports, ok := moduleExports(src); !ok || len(exports) > 0 {
+			return -1
+		}
+		candidates = topLevelBlocks(src, transformed)
+	} else {
+		candidates = bracesBefore(src, errorOffset(src, transformed.Errors[0]))
+	}
+
+	offset := -1
+	for _, candidate := range candidates {
+		withExport := src[:candidate] + exportDefault + src[candidate:]
+		if len(transform(withExport, api.SourceMapNone).Errors) > 0 {
+			continue
+		}
+		exports, ok := moduleExports(withExport)
+		if !ok || len(exports) == 0 {
+			// The brace was in a string, comment or regular expression.
+			continue
+		}
+		if len(exports) > 1 || exports[0] != "default" {
+			// The file has explicit exports.
+			return -1
+		}
+		if offset != -1 {
+			return -1
+		}
+		offset = candidate
+		if len(transformed.Errors) > 0 {
+			// The object contains the syntax error, so no brace before it can
+			// also start an object that contains the error.
+			break
+		}
+	}
+	return offset
+}
+
+func transform(src string, sourceMap api.SourceMap) api.TransformResult {
+	return api.Transform(src, api.TransformOptions{
+		Loader:    api.LoaderTS,
+		Sourcemap: sourceMap,
+		LogLevel:  api.LogLevelSilent,
+	})
+}
+
+// moduleExports returns the names that the TypeScript module in src exports,
+// according to esbuild. It returns false if src doesn't parse.
+func moduleExports(src string) ([]string, bool) {
+	result := api.Build(api.BuildOptions{
+		Stdin: &api.StdinOptions{
+			Contents: src,
+			Loader:   api.LoaderTS,
+		},
+		Format:   api.FormatESModule,
+		Metafile: true,
+		LogLevel: api.LogLevelSilent,
+	})
+	if len(result.Errors) > 0 {
+		return nil, false
+	}
+
+	var metafile struct {
+		Outputs map[string]struct {
+			Exports []string `json:"exports"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal([]byte(result.Metafile), &metafile); err != nil {
+		return nil, false
+	}
+	var exports []string
+	for _, output := range metafile.Outputs {
+		exports = append(exports, output.Exports...)
+	}
+	return exports, true
+}
+
+// topLevelBlocks returns the offsets in src of the top-level block statements
+// of a file that parses. esbuild prints them at the start of a line, and its
+// source map points back to their opening brace.
+func topLevelBlocks(src string, transformed api.TransformResult) []int {
+	consumer, err := sourcemap.Parse("", transformed.Map)
+	if err != nil {
+		return nil
+	}
+	var offsets []int
+	for i, line := range strings.Split(string(transformed.Code), "\n") {
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		_, _, srcLine, srcColumn, ok := consumer.Source(i+1, 0)
+		if !ok {
+			continue
+		}
+		start := lineOffset(src, srcLine)
+		offset := start + utf16Offset(src[start:], srcColumn)
+		if offset < len(src) && src[offset] == '{' {
+			offsets = append(offsets, offset)
+		}
+	}
+	return offsets
+}
+
+// bracesBefore returns the offsets of the curly braces before end, from the
+// nearest one.
+func bracesBefore(src string, end int) []int {
+	var offsets []int
+	for len(offsets) < maxCandidates {
+		end = strings.LastIndexByte(src[:end], '{')
+		if end == -1 {
+			break
+		}
+		offsets = append(offsets, end)
+	}
+	return offsets
+}
+
+// errorOffset returns the offset in src of an esbuild error, whose column is
+// in bytes.
+func errorOffset(src string, msg api.Message) int {
+	if msg.Location == nil {
+		return len(src)
+	}
+	return min(lineOffset(src, msg.Location.Line)+msg.Location.Column, len(src))
+}
+
+// lineOffset returns the offset of a 1-based line.
+func lineOffset(src string, line int) int {
+	offset := 0
+	for ; line > 1; line-- {
+		i := strings.IndexByte(src[offset:], '\n')
+		if i == -1 {
+			return len(src)
+		}
+		offset += i + 1
+	}
+	return offset
+}
+
+// utf16Offset returns the byte offset of a column in UTF-16 code units, which
+// is how source maps count columns.
+func utf16Offset(line string, column int) int {
+	offset := 0
+	for offset < len(line) && column > 0 {
+		r, size := utf8.DecodeRuneInString(line[offset:])
+		if r >= 0x10000 {
+			column -= 2
+		} else {
+			column--
+		}
+		offset += size
+	}
+	return offset
+}
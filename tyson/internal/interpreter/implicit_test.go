@@ -0,0 +1,35 @@
+package interpreter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestImplicitExport evaluates every .tson file in testdata/implicit, and
+// compares its default export to the .json file with the same name. Files
+// that shouldn't implicitly export anything evaluate to null.
+func TestImplicitExport(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "implicit", "*.tson"))
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".tson")
+		t.Run(name, func(t *testing.T) {
+			expected, err := os.ReadFile(strings.TrimSuffix(input, ".tson") + ".json")
+			require.NoError(t, err)
+
+			val, err := Eval(input)
+			require.NoError(t, err)
+			actual, err := json.Marshal(val)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expected), string(actual))
+		})
+	}
+}
@@ -1,15 +1,66 @@
 package interpreter
 
 import (
+	"os"
+	"path/filepath"
+
 	"github.com/dop251/goja"
 	"github.com/evanw/esbuild/pkg/api"
 	"go.jetpack.io/tyson/internal/tsembed"
 )
 
+// PathEnv is the environment variable that lists additional directories, in
+// the same format as PATH, that bare imports are resolved from.
+const PathEnv = "TYSON_PATH"
+
+// resolveExtensions are tried in order when an import doesn't specify an
+// extension. They are esbuild's defaults, plus the data formats and .tson.
+var resolveExtensions = []string{
+	".tson", ".tsx", ".ts", ".jsx", ".js", ".json", ".yaml", ".yml",
+}
+
 func Eval(entrypoint string) (goja.Value, error) {
-	return tsembed.Eval(entrypoint, tsembed.Options{
+	return tsembed.Eval(entrypoint, options())
+}
+
+func EvalExport(entrypoint string, name string) (goja.Value, error) {
+	return tsembed.EvalExport(entrypoint, name, options())
+}
+
+func EvalExports(entrypoint string) (map[string]goja.Value, error) {
+	return tsembed.EvalExports(entrypoint, options())
+}
+
+// Compile bundles the entrypoint without evaluating it.
+func Compile(entrypoint string) (*tsembed.Bundle, error) {
+	return tsembed.Compile(entrypoint, options())
+}
+
+// CompileCached is like Compile, but reuses the bundle cached in cacheDir if
+// none of its inputs have changed.
+func CompileCached(entrypoint string, cacheDir string) (*tsembed.Bundle, error) {
+	opts := options()
+	opts.CacheDir = cacheDir
+	return tsembed.Compile(entrypoint, opts)
+}
+
+func options() tsembed.Options {
+	return tsembed.Options{
+		NodePaths: searchPath(),
 		Plugins: []api.Plugin{
 			tsonTransform,
+			dataImport,
 		},
-	})
+		ResolveExtensions: resolveExtensions,
+	}
+}
+
+func searchPath() []string {
+	paths := []string{}
+	for _, path := range filepath.SplitList(os.Getenv(PathEnv)) {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
 }
@@ -0,0 +1,215 @@
+package tsembed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// cacheVersion is part of every cache key. Bump it whenever the way bundles
+// are built changes, so that stale bundles are never used.
+const cacheVersion = "2"
+
+// cacheEntry is a bundle as stored in the cache directory.
+type cacheEntry struct {
+	// Inputs maps the path of every input to the SHA-256 of its contents when
+	// the bundle was built.
+	Inputs  map[string]string `json:"inputs"`
+	Imports []resolvedImport  `json:"imports"`
+	Code    []byte            `json:"code"`
+}
+
+// resolvedImport is an import of one of the inputs, and the file it resolved
+// to.
+type resolvedImport struct {
+	// Importer is the absolute path of the file with the import.
+	Importer string `json:"importer"`
+	// Path is the path as written in the import, e.g. "./base".
+	Path string `json:"path"`
+	// Kind is esbuild's kind of import, e.g. "import-statement".
+	Kind string `json:"kind"`
+	// Resolved is the absolute path of the file that the import resolved to.
+	Resolved string `json:"resolved"`
+}
+
+// resolveKinds maps the import kinds in esbuild's metafile to the kinds that
+// its Resolve takes.
+var resolveKinds = map[string]api.ResolveKind{
+	"import-statement": api.ResolveJSImportStatement,
+	"require-call":     api.ResolveJSRequireCall,
+	"dynamic-import":   api.ResolveJSDynamicImport,
+	"require-resolve":  api.ResolveJSRequireResolve,
+}
+
+// loadCached returns the cached bundle for entrypoint, or nil if there's none,
+// any of its inputs have changed since it was built, or any of its imports
+// now resolve to a different file. Imports can resolve differently without
+// any input changing, e.g. when a base.tson is added next to a base.ts, or a
+// package is installed.
+func loadCached(entrypoint string, opts Options) *Bundle {
+	path, err := cachePath(entrypoint, opts)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || len(entry.Inputs) == 0 {
+		return nil
+	}
+
+	inputs := make([]string, 0, len(entry.Inputs))
+	for input, hash := range entry.Inputs {
+		current, err := hashFile(input)
+		if err != nil || current != hash {
+			return nil
+		}
+		inputs = append(inputs, input)
+	}
+	if !resolveSame(entrypoint, entry.Imports, opts) {
+		return nil
+	}
+	sort.Strings(inputs)
+	return &Bundle{
+		Entrypoint: entrypoint,
+		Code:       entry.Code,
+		Inputs:     inputs,
+		imports:    entry.Imports,
+	}
+}
+
+// resolveSame reports whether every import still resolves to the same file.
+// Resolving is much cheaper than bundling: esbuild only looks for files, and
+// doesn't read them.
+func resolveSame(entrypoint string, imports []resolvedImport, opts Options) bool {
+	same := true
+	check := api.Plugin{
+		Name: "tyson-cache",
+		Setup: func(build api.PluginBuild) {
+			build.OnStart(func() (api.OnStartResult, error) {
+				for _, imp := range imports {
+					kind, ok := resolveKinds[imp.Kind]
+					if !ok {
+						same = false
+						break
+					}
+					result := build.Resolve(imp.Path, api.ResolveOptions{
+						Importer:   imp.Importer,
+						Namespace:  "file",
+						ResolveDir: filepath.Dir(imp.Importer),
+						Kind:       kind,
+					})
+					if len(result.Errors) > 0 || result.Path != imp.Resolved {
+						same = false
+						break
+					}
+				}
+				return api.OnStartResult{}, nil
+			})
+		},
+	}
+
+	// Resolve with the same options as the bundle, but without bundling
+	// anything.
+	buildOpts := buildOptions(entrypoint, opts)
+	buildOpts.EntryPoints = nil
+	buildOpts.Stdin = &api.StdinOptions{Contents: ""}
+	buildOpts.Plugins = append([]api.Plugin{check}, opts.Plugins...)
+	buildOpts.Metafile = false
+	buildOpts.Sourcemap = api.SourceMapNone
+	buildOpts.LogLevel = api.LogLevelSilent
+	api.Build(buildOpts)
+	return same
+}
+
+// storeCached writes the bundle to the cache directory, along with the
+// hashes of its inputs.
+func storeCached(bundle *Bundle, opts Options) error {
+	path, err := cachePath(bundle.Entrypoint, opts)
+	if err != nil {
+		return err
+	}
+	entry := cacheEntry{
+		Inputs:  make(map[string]string, len(bundle.Inputs)),
+		Imports: bundle.imports,
+		Code:    bundle.Code,
+	}
+	for _, input := range bundle.Inputs {
+		hash, err := hashFile(input)
+		if err != nil {
+			return err
+		}
+		entry.Inputs[input] = hash
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(opts.CacheDir, 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first, since other processes may be reading
+	// the cache concurrently.
+	tmp, err := os.CreateTemp(opts.CacheDir, ".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// cachePath returns where the bundle for entrypoint is cached. It depends on
+// everything, other than the contents of the inputs, that affects the bundle.
+func cachePath(entrypoint string, opts Options) (string, error) {
+	abs, err := filepath.Abs(entrypoint)
+	if err != nil {
+		return "", err
+	}
+	// Paths in source maps are relative to the working directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	parts := []string{cacheVersion, abs, wd}
+	parts = append(parts, opts.NodePaths...)
+	parts = append(parts, opts.ResolveExtensions...)
+	for _, plugin := range opts.Plugins {
+		parts = append(parts, plugin.Name)
+	}
+	for _, part := range parts {
+		// Null bytes can't appear in paths, so they separate parts unambiguously.
+		io.WriteString(h, part+"\x00")
+	}
+	return filepath.Join(opts.CacheDir, hex.EncodeToString(h.Sum(nil))+".json"), nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,429 @@
+package tsembed
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// ExportOptions configure how JavaScript values are converted to JSON.
+type ExportOptions struct {
+	// Path is the JSON path of the value being exported, used when reporting
+	// losses. Defaults to "$".
+	Path string
+	// Strict makes any value that can't be represented in JSON an error,
+	// instead of dropping it.
+	Strict bool
+	// OnLoss, if set, is called for every value that is dropped or replaced
+	// with null because it can't be represented in JSON. It's never called in
+	// strict mode.
+	OnLoss func(Loss)
+	// MaxSize, if positive, is the maximum size of the JSON in bytes. Exporting
+	// stops with ErrOutputTooLarge as soon as it's exceeded, so that large
+	// values aren't converted in full.
+	MaxSize int
+}
+
+// ErrOutputTooLarge is returned when the JSON exceeds ExportOptions.MaxSize.
+var ErrOutputTooLarge = errors.New("output too large")
+
+// Loss describes a value that couldn't be represented in JSON.
+type Loss struct {
+	// Path is the JSON path of the value, e.g. $.servers[0].handler
+	Path string
+	// Reason describes the value, e.g. "function"
+	Reason string
+}
+
+func (l Loss) String() string {
+	return fmt.Sprintf("%s: %s", l.Path, l.Reason)
+}
+
+// UnrepresentableError is returned in strict mode for the first value that
+// can't be represented in JSON.
+type UnrepresentableError struct {
+	Loss
+}
+
+func (e *UnrepresentableError) Error() string {
+	return fmt.Sprintf("value at %s can't be represented in JSON: %s", e.Path, e.Reason)
+}
+
+// maxSafeInteger is the largest integer that a JavaScript number (a float64)
+// can represent exactly.
+const maxSafeInteger = 1<<53 - 1
+
+// MarshalJSON converts a value produced by running a bundle into compact JSON.
+// Unlike JSON.stringify, it converts Dates to RFC 3339 strings, Maps to
+// objects, Sets to arrays and BigInts to numbers (or strings, if they're too
+// large to be represented exactly). Values that have no JSON representation,
+// such as functions, undefined or cyclic references, are dropped from objects
+// and replaced with null elsewhere, and reported through opts.OnLoss.
+//
+// MarshalJSON may run user code, such as getters and toJSON methods, so it
+// should be called from within Bundle.Run.
+func MarshalJSON(v goja.Value, opts ExportOptions) (result []byte, err error) {
+	// Getters called while exporting throw exceptions as panics.
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case *goja.Exception:
+			err = r
+		default:
+			panic(r)
+		}
+	}()
+
+	if opts.Path == "" {
+		opts.Path = "$"
+	}
+	e := &exporter{
+		opts:      opts,
+		ancestors: map[*goja.Object]bool{},
+	}
+	if err := e.write(v, opts.Path); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type exporter struct {
+	opts ExportOptions
+	buf  bytes.Buffer
+	// ancestors are the objects on the path to the value being exported, used
+	// to detect cycles.
+	ancestors map[*goja.Object]bool
+}
+
+// write writes v as JSON. Values that can't be represented are written as
+// null.
+func (e *exporter) write(v goja.Value, path string) error {
+	if err := e.checkSize(0); err != nil {
+		return err
+	}
+	if ok, err := e.representable(v, path); err != nil {
+		return err
+	} else if !ok {
+		e.buf.WriteString("null")
+		return nil
+	}
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		e.buf.WriteString("null")
+		return nil
+	}
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return e.writePrimitive(v, path)
+	}
+
+	e.ancestors[obj] = true
+	defer delete(e.ancestors, obj)
+
+	switch className(obj) {
+	case "Date":
+		t, ok := obj.Export().(time.Time)
+		if !ok {
+			// Invalid dates, such as new Date("foo"), have no time.
+			return e.lossAsNull(path, "invalid Date")
+		}
+		return e.writeString(t.UTC().Format(time.RFC3339Nano))
+	case "Map":
+		return e.writeMap(obj, path)
+	case "Set":
+		return e.writeSet(obj, path)
+	case "Array":
+		return e.writeArray(obj, path)
+	// Boxed primitives, such as new String("foo"):
+	case "String":
+		return e.writePrimitive(obj.ToString(), path)
+	case "Number":
+		return e.writePrimitive(obj.ToNumber(), path)
+	case "Boolean":
+		e.buf.WriteString(strconv.FormatBool(obj.ToBoolean()))
+		return nil
+	}
+
+	if toJSON, ok := goja.AssertFunction(obj.Get("toJSON")); ok {
+		result, err := toJSON(obj)
+		if err != nil {
+			return err
+		}
+		return e.write(result, path)
+	}
+	return e.writeObject(obj, path)
+}
+
+// representable reports whether v can be written as JSON. When it can't, the
+// loss is reported, or an error returned in strict mode.
+func (e *exporter) representable(v goja.Value, path string) (bool, error) {
+	reason := ""
+	if v != nil && goja.IsUndefined(v) {
+		reason = "undefined"
+	} else if _, ok := goja.AssertFunction(v); ok {
+		reason = "function"
+	} else if _, ok := v.(*goja.Symbol); ok {
+		reason = "symbol"
+	} else if obj, ok := v.(*goja.Object); ok && e.ancestors[obj] {
+		reason = "cyclic reference"
+	} else if ok {
+		switch class := className(obj); class {
+		case "RegExp", "Promise", "WeakMap", "WeakSet", "Error":
+			reason = class
+		}
+	}
+
+	if reason == "" {
+		return true, nil
+	}
+	return false, e.loss(path, reason)
+}
+
+func (e *exporter) loss(path string, reason string) error {
+	loss := Loss{Path: path, Reason: reason}
+	if e.opts.Strict {
+		return &UnrepresentableError{Loss: loss}
+	}
+	if e.opts.OnLoss != nil {
+		e.opts.OnLoss(loss)
+	}
+	return nil
+}
+
+func (e *exporter) lossAsNull(path string, reason string) error {
+	if err := e.loss(path, reason); err != nil {
+		return err
+	}
+	e.buf.WriteString("null")
+	return nil
+}
+
+func (e *exporter) writePrimitive(v goja.Value, path string) error {
+	switch exported := v.Export().(type) {
+	case nil:
+		e.buf.WriteString("null")
+	case bool:
+		e.buf.WriteString(strconv.FormatBool(exported))
+	case string:
+		return e.writeString(exported)
+	case int64:
+		e.buf.WriteString(strconv.FormatInt(exported, 10))
+	case float64:
+		if math.IsNaN(exported) || math.IsInf(exported, 0) {
+			return e.lossAsNull(path, v.String())
+		}
+		// Use JavaScript's number formatting, same as JSON.stringify.
+		e.buf.WriteString(v.String())
+	case *big.Int:
+		// BigInts that fit in a float64 become numbers, and larger ones become
+		// strings so that no precision is lost.
+		if exported.IsInt64() && exported.Int64() <= maxSafeInteger && exported.Int64() >= -maxSafeInteger {
+			e.buf.WriteString(exported.String())
+		} else {
+			return e.writeString(exported.String())
+		}
+	default:
+		return e.lossAsNull(path, fmt.Sprintf("unsupported value of type %T", exported))
+	}
+	return nil
+}
+
+// checkSize returns ErrOutputTooLarge if writing n more bytes would exceed
+// the maximum size.
+func (e *exporter) checkSize(n int) error {
+	if e.opts.MaxSize > 0 && e.buf.Len()+n > e.opts.MaxSize {
+		return ErrOutputTooLarge
+	}
+	return nil
+}
+
+func (e *exporter) writeString(s string) error {
+	// Quoting only makes strings longer.
+	if err := e.checkSize(len(s)); err != nil {
+		return err
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	e.buf.Write(b)
+	return nil
+}
+
+func (e *exporter) writeObject(obj *goja.Object, path string) error {
+	e.buf.WriteByte('{')
+	first := true
+	for _, key := range obj.Keys() {
+		val := obj.Get(key)
+		keyPath := propertyPath(path, key)
+		if ok, err := e.representable(val, keyPath); err != nil {
+			return err
+		} else if !ok {
+			// Same as JSON.stringify, properties that can't be represented are
+			// left out.
+			continue
+		}
+		if !first {
+			e.buf.WriteByte(',')
+		}
+		first = false
+		if err := e.writeString(key); err != nil {
+			return err
+		}
+		e.buf.WriteByte(':')
+		if err := e.write(val, keyPath); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
+
+func (e *exporter) writeArray(obj *goja.Object, path string) error {
+	e.buf.WriteByte('[')
+	length := obj.Get("length").ToInteger()
+	for i := int64(0); i < length; i++ {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		if err := e.write(obj.Get(strconv.FormatInt(i, 10)), indexPath(path, i)); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte(']')
+	return nil
+}
+
+func (e *exporter) writeMap(obj *goja.Object, path string) error {
+	e.buf.WriteByte('{')
+	first := true
+	err := iterate(obj, "entries", func(entry goja.Value) error {
+		pair, ok := entry.(*goja.Object)
+		if !ok {
+			return fmt.Errorf("unexpected Map entry %s", entry)
+		}
+		key, val := pair.Get("0"), pair.Get("1")
+		name, ok := mapKey(key)
+		if !ok {
+			return e.loss(path, fmt.Sprintf("Map key %s that isn't a string or number", key))
+		}
+		keyPath := propertyPath(path, name)
+		if ok, err := e.representable(val, keyPath); err != nil || !ok {
+			return err
+		}
+		if !first {
+			e.buf.WriteByte(',')
+		}
+		first = false
+		if err := e.writeString(name); err != nil {
+			return err
+		}
+		e.buf.WriteByte(':')
+		return e.write(val, keyPath)
+	})
+	if err != nil {
+		return err
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
+
+func (e *exporter) writeSet(obj *goja.Object, path string) error {
+	e.buf.WriteByte('[')
+	i := int64(0)
+	err := iterate(obj, "values", func(val goja.Value) error {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		err := e.write(val, indexPath(path, i))
+		i++
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	e.buf.WriteByte(']')
+	return nil
+}
+
+// className returns the built-in class of obj. goja reports Maps, Sets and
+// other built-ins as plain objects, so for those we rely on their
+// Symbol.toStringTag, which is what Object.prototype.toString uses too.
+func className(obj *goja.Object) string {
+	class := obj.ClassName()
+	if class != "Object" {
+		return class
+	}
+	if tag := obj.GetSymbol(goja.SymToStringTag); tag != nil && !goja.IsUndefined(tag) {
+		return tag.String()
+	}
+	return class
+}
+
+// iterate calls fn with every value of the iterator returned by obj[method]().
+func iterate(obj *goja.Object, method string, fn func(goja.Value) error) error {
+	newIterator, ok := goja.AssertFunction(obj.Get(method))
+	if !ok {
+		return fmt.Errorf("%s has no %s method", obj.ClassName(), method)
+	}
+	it, err := newIterator(obj)
+	if err != nil {
+		return err
+	}
+	iterator, ok := it.(*goja.Object)
+	if !ok {
+		return fmt.Errorf("%s.%s() did not return an iterator", obj.ClassName(), method)
+	}
+	next, ok := goja.AssertFunction(iterator.Get("next"))
+	if !ok {
+		return fmt.Errorf("%s.%s() did not return an iterator", obj.ClassName(), method)
+	}
+
+	for {
+		res, err := next(iterator)
+		if err != nil {
+			return err
+		}
+		result, ok := res.(*goja.Object)
+		if !ok {
+			return fmt.Errorf("iterator returned %s instead of an object", res)
+		}
+		if result.Get("done").ToBoolean() {
+			return nil
+		}
+		if err := fn(result.Get("value")); err != nil {
+			return err
+		}
+	}
+}
+
+// mapKey converts a Map key into an object key. Only strings and numbers have
+// an unambiguous representation.
+func mapKey(key goja.Value) (string, bool) {
+	switch key.Export().(type) {
+	case string, int64, float64:
+		return key.String(), true
+	}
+	return "", false
+}
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func propertyPath(path string, key string) string {
+	if identifierRegex.MatchString(key) {
+		return path + "." + key
+	}
+	quoted, _ := json.Marshal(key)
+	return path + "[" + string(quoted) + "]"
+}
+
+func indexPath(path string, i int64) string {
+	return path + "[" + strconv.FormatInt(i, 10) + "]"
+}
@@ -0,0 +1,126 @@
+package tsembed
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		losses   []string
+	}{
+		{
+			name:     "plain values keep their order",
+			input:    `export default { b: "string", a: 1.5, c: [true, null], d: { e: -0 } }`,
+			expected: `{"b":"string","a":1.5,"c":[true,null],"d":{"e":0}}`,
+		},
+		{
+			name:     "dates",
+			input:    `export default { d: new Date("2024-03-04T05:06:07.089Z") }`,
+			expected: `{"d":"2024-03-04T05:06:07.089Z"}`,
+		},
+		{
+			name:     "invalid dates",
+			input:    `export default { d: new Date("not a date") }`,
+			expected: `{"d":null}`,
+			losses:   []string{"$.d: invalid Date"},
+		},
+		{
+			name:     "maps and sets",
+			input:    `export default { m: new Map<any, any>([["a", 1], [2, "b"]]), s: new Set([1, 1, 2]) }`,
+			expected: `{"m":{"a":1,"2":"b"},"s":[1,2]}`,
+		},
+		{
+			name:     "functions and undefined",
+			input:    `export default { f() {}, u: undefined, a: [undefined, () => 1] }`,
+			expected: `{"a":[null,null]}`,
+			losses:   []string{"$.f: function", "$.u: undefined", "$.a[0]: undefined", "$.a[1]: function"},
+		},
+		{
+			name:     "non-finite numbers",
+			input:    `export default { "not-a-number": NaN, inf: Infinity }`,
+			expected: `{"not-a-number":null,"inf":null}`,
+			losses:   []string{`$["not-a-number"]: NaN`, "$.inf: Infinity"},
+		},
+		{
+			name:     "cycles",
+			input:    `const a: any = { name: "a" }; a.self = a; export default { a }`,
+			expected: `{"a":{"name":"a"}}`,
+			losses:   []string{"$.a.self: cyclic reference"},
+		},
+		{
+			name:     "repeated references aren't cycles",
+			input:    `const a = { name: "a" }; export default { x: a, y: [a] }`,
+			expected: `{"x":{"name":"a"},"y":[{"name":"a"}]}`,
+		},
+		{
+			name:     "toJSON",
+			input:    `export default { v: { toJSON() { return "custom" } } }`,
+			expected: `{"v":"custom"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			losses := []string{}
+			var result []byte
+			runDefault(t, tt.input, func(v goja.Value) error {
+				var err error
+				result, err = MarshalJSON(v, ExportOptions{
+					OnLoss: func(loss Loss) { losses = append(losses, loss.String()) },
+				})
+				return err
+			})
+			assert.Equal(t, tt.expected, string(result))
+			if tt.losses == nil {
+				tt.losses = []string{}
+			}
+			assert.Equal(t, tt.losses, losses)
+		})
+	}
+}
+
+func TestMarshalJSONStrict(t *testing.T) {
+	runDefault(t, `export default { servers: [{ handler: () => 1 }] }`, func(v goja.Value) error {
+		_, err := MarshalJSON(v, ExportOptions{Strict: true})
+		var unrepresentable *UnrepresentableError
+		require.ErrorAs(t, err, &unrepresentable)
+		assert.Equal(t, "$.servers[0].handler", unrepresentable.Path)
+		return nil
+	})
+}
+
+func TestMarshalJSONMaxSize(t *testing.T) {
+	input := `export default { small: "x", items: Array.from({ length: 1000 }, (_, i) => ({ i })) }`
+	runDefault(t, input, func(v goja.Value) error {
+		result, err := MarshalJSON(v, ExportOptions{MaxSize: 100})
+		assert.ErrorIs(t, err, ErrOutputTooLarge)
+		assert.Nil(t, result)
+
+		_, err = MarshalJSON(v, ExportOptions{MaxSize: 100_000})
+		assert.NoError(t, err)
+		return nil
+	})
+}
+
+// runDefault evaluates input and calls fn with its default export.
+func runDefault(t *testing.T, input string, fn func(goja.Value) error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "input.ts")
+	require.NoError(t, os.WriteFile(path, []byte(input), 0o644))
+	bundle, err := Compile(path, Options{})
+	require.NoError(t, err)
+	err = bundle.Run(context.Background(), Limits{}, func(_ *goja.Runtime, exports map[string]goja.Value) error {
+		return fn(exports[DefaultExport])
+	})
+	require.NoError(t, err)
+}
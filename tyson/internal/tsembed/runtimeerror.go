@@ -0,0 +1,94 @@
+package tsembed
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+	esbuild "github.com/evanw/esbuild/pkg/api"
+	"go.jetpack.io/tyson/msgerror"
+)
+
+// bundleName is the file name under which bundles are run. Source map paths
+// are resolved relative to it, so it must be in the working directory, same as
+// the paths esbuild writes into the source map.
+const bundleName = "bundle.js"
+
+// stackFrameRegex matches a frame of a goja stack trace, such as
+// "at boom (lib.ts:2:15(3))" or "at main.tson:4:10(36)".
+var stackFrameRegex = regexp.MustCompile(`^\s*at (?:.* \()?(.+):(\d+):(\d+)\(\d+\)\)?$`)
+
+const sourceMapPrefix = "//# sourceMappingURL=data:application/json;base64,"
+
+// runtimeError converts an exception thrown while running the bundle into an
+// error that points at the original source file, using the bundle's inline
+// source map.
+func runtimeError(b *Bundle, ex *goja.Exception) error {
+	text := ex.Error()
+	if ex.Value() != nil {
+		text = ex.Value().String()
+	}
+	msg := esbuild.Message{Text: text, Location: b.location(ex)}
+	toplevel := fmt.Sprintf("runtime error when evaluating %s: %s", b.Entrypoint, text)
+	return msgerror.ErrFromMessages(toplevel, []esbuild.Message{msg})
+}
+
+// location returns the location of the innermost stack frame of ex that is in
+// one of the original source files, or nil if there's none.
+func (b *Bundle) location(ex *goja.Exception) *esbuild.Location {
+	// goja doesn't expose the stack frames of an exception, so we parse its
+	// stack trace. Positions in it have already been mapped to the original
+	// sources.
+	for _, line := range strings.Split(ex.String(), "\n") {
+		match := stackFrameRegex.FindStringSubmatch(line)
+		if match == nil || match[1] == bundleName {
+			continue
+		}
+		lineNum, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+		loc := &esbuild.Location{
+			File:   match[1],
+			Line:   lineNum,
+			Column: max(column-1, 0), // goja columns are 1-based
+		}
+		if lines := b.sourceLines(match[1]); lineNum > 0 && lineNum <= len(lines) {
+			loc.LineText = lines[lineNum-1]
+		}
+		return loc
+	}
+	return nil
+}
+
+// sourceLines returns the lines of a source file as it was compiled, taken
+// from the source map. For .tson files these may differ slightly from the file
+// on disk, but they're what stack positions refer to.
+func (b *Bundle) sourceLines(file string) []string {
+	i := bytes.LastIndex(b.Code, []byte(sourceMapPrefix))
+	if i == -1 {
+		return nil
+	}
+	encoded := bytes.TrimSpace(b.Code[i+len(sourceMapPrefix):])
+	data, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil
+	}
+	var sourceMap struct {
+		Sources        []string `json:"sources"`
+		SourcesContent []string `json:"sourcesContent"`
+	}
+	if err := json.Unmarshal(data, &sourceMap); err != nil {
+		return nil
+	}
+	for i, source := range sourceMap.Sources {
+		if path.Join(path.Dir(bundleName), source) == file && i < len(sourceMap.SourcesContent) {
+			return strings.Split(sourceMap.SourcesContent[i], "\n")
+		}
+	}
+	return nil
+}
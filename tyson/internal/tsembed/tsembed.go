@@ -1,40 +1,275 @@
 package tsembed
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/dop251/goja"
+	"github.com/dop251/goja/parser"
 	"github.com/evanw/esbuild/pkg/api"
 	"go.jetpack.io/tyson/msgerror"
 )
 
 type Options struct {
 	Plugins []api.Plugin
+	// NodePaths are additional directories that bare imports (such as
+	// "@company/base-config") are resolved from, after any node_modules
+	// directories. Same as NODE_PATH in node.
+	NodePaths []string
+	// ResolveExtensions overrides the extensions that are tried, in order, when
+	// an import doesn't specify one. If empty, esbuild's defaults are used.
+	ResolveExtensions []string
+	// CacheDir, if set, is a directory where compiled bundles are cached, so
+	// that compiling an entrypoint whose inputs haven't changed doesn't need to
+	// bundle it again.
+	CacheDir string
 }
 
+// DefaultExport is the name under which the default export of a module is
+// stored.
+const DefaultExport = "default"
+
+// Bundle is an entrypoint compiled, together with everything it imports, into
+// a single script. A bundle can be run many times, concurrently.
+type Bundle struct {
+	Entrypoint string
+	Code       []byte
+	// Inputs are the absolute paths of every file that went into the bundle,
+	// including the entrypoint.
+	Inputs []string
+
+	// imports are how the imports of the inputs were resolved, which the
+	// cache checks before reusing the bundle.
+	imports []resolvedImport
+
+	// program is Code parsed by goja, which can be shared by every run.
+	compileOnce sync.Once
+	program     *goja.Program
+	compileErr  error
+}
+
+// Eval evaluates the entrypoint and returns its default export.
 func Eval(entrypoint string, opts Options) (goja.Value, error) {
-	bundle, err := Build(entrypoint, opts)
+	return EvalExport(entrypoint, DefaultExport, opts)
+}
+
+// EvalExport evaluates the entrypoint and returns the export with the given
+// name. It returns an error if the module has no such export.
+func EvalExport(entrypoint string, name string, opts Options) (goja.Value, error) {
+	bundle, err := Compile(entrypoint, opts)
 	if err != nil {
 		return nil, err
 	}
-	return evalJS(string(bundle))
+	return bundle.EvalExport(name)
 }
 
-func evalJS(code string) (goja.Value, error) {
+// EvalExports evaluates the entrypoint and returns all of its exports, keyed by
+// export name.
+func EvalExports(entrypoint string, opts Options) (map[string]goja.Value, error) {
+	bundle, err := Compile(entrypoint, opts)
+	if err != nil {
+		return nil, err
+	}
+	return bundle.EvalExports()
+}
+
+// EvalExport runs the bundle and returns the export with the given name.
+func (b *Bundle) EvalExport(name string) (goja.Value, error) {
+	exports, err := b.EvalExports()
+	if err != nil {
+		return nil, err
+	}
+	return b.Export(exports, name)
+}
+
+// EvalExports runs the bundle and returns all of its exports.
+func (b *Bundle) EvalExports() (map[string]goja.Value, error) {
+	var result map[string]goja.Value
+	err := b.Run(context.Background(), Limits{}, func(_ *goja.Runtime, exports map[string]goja.Value) error {
+		result = exports
+		return nil
+	})
+	return result, err
+}
+
+// Export returns the export with the given name from exports, which must come
+// from running b. It returns an error if the bundle has no such export.
+func (b *Bundle) Export(exports map[string]goja.Value, name string) (goja.Value, error) {
+	val, ok := exports[name]
+	if !ok {
+		// A missing default export evaluates to null, same as before named
+		// exports were supported.
+		if name == DefaultExport {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("export %q not found in %s", name, b.Entrypoint)
+	}
+	return val, nil
+}
+
+// Limits bound the resources that running a bundle may use. Zero values mean
+// no limit.
+type Limits struct {
+	// Timeout is the maximum amount of time the script may run for.
+	Timeout time.Duration
+	// MaxCallDepth is the maximum depth of the JavaScript call stack.
+	MaxCallDepth int
+}
+
+var (
+	// ErrTimeout is returned when a script runs for longer than its timeout.
+	ErrTimeout = errors.New("evaluation timed out")
+	// ErrCallDepthExceeded is returned when a script exceeds its maximum call
+	// depth, usually because of infinite recursion.
+	ErrCallDepthExceeded = errors.New("maximum call depth exceeded")
+)
+
+// Run runs the bundle in a new runtime and calls fn with the runtime and the
+// bundle's exports. The limits apply to fn as well, since reading exported
+// values can run user code (getters, toJSON, etc.). The runtime and exports
+// must not be used after fn returns.
+func (b *Bundle) Run(
+	ctx context.Context,
+	limits Limits,
+	fn func(vm *goja.Runtime, exports map[string]goja.Value) error,
+) error {
+	program, err := b.compile()
+	if err != nil {
+		return err
+	}
+
 	vm := goja.New()
-	_, err := vm.RunString(code)
+	if limits.MaxCallDepth > 0 {
+		vm.SetMaxCallStackSize(limits.MaxCallDepth)
+	}
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+	stop := context.AfterFunc(ctx, func() {
+		vm.Interrupt(ctx.Err())
+	})
+	defer stop()
+
+	err = limitError(runUncatchable(func() error {
+		exports, err := evalJS(vm, program)
+		if err != nil {
+			return err
+		}
+		return fn(vm, exports)
+	}), limits)
+	var ex *goja.Exception
+	if errors.As(err, &ex) {
+		return runtimeError(b, ex)
+	}
+	return err
+}
+
+// runUncatchable calls fn and returns, as an error, any interrupt or stack
+// overflow that goja raised as a panic. goja returns these as errors from
+// RunString, but panics with them when they happen while Go code, such as
+// json.Marshal, is calling into JavaScript.
+func runUncatchable(fn func() error) (err error) {
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case *goja.InterruptedError:
+			err = r
+		case *goja.StackOverflowError:
+			err = r
+		default:
+			panic(r)
+		}
+	}()
+	return fn()
+}
+
+// limitError converts errors caused by exceeding one of the limits into the
+// corresponding typed error.
+func limitError(err error, limits Limits) error {
+	var interrupted *goja.InterruptedError
+	var overflow *goja.StackOverflowError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &interrupted) && errors.Is(err, context.DeadlineExceeded):
+		if limits.Timeout > 0 {
+			return fmt.Errorf("%w after %s", ErrTimeout, limits.Timeout)
+		}
+		return ErrTimeout
+	case errors.As(err, &interrupted) && interrupted.Unwrap() != nil:
+		// Canceled by the caller.
+		return interrupted.Unwrap()
+	case errors.As(err, &overflow):
+		return fmt.Errorf("%w (%d)", ErrCallDepthExceeded, limits.MaxCallDepth)
+	}
+	return err
+}
+
+// compile parses the bundle's code the first time it's called.
+func (b *Bundle) compile() (*goja.Program, error) {
+	b.compileOnce.Do(func() {
+		ast, err := goja.Parse(bundleName, string(b.Code))
+		if err != nil {
+			// goja fails to parse source maps without any mappings, which esbuild
+			// generates for files without code. They aren't needed to run the
+			// bundle, only to report errors.
+			ast, err = goja.Parse(bundleName, string(b.Code), parser.WithDisableSourceMaps)
+		}
+		if err != nil {
+			b.compileErr = err
+			return
+		}
+		b.program, b.compileErr = goja.CompileAST(ast, false)
+	})
+	return b.program, b.compileErr
+}
+
+// Call calls fn with args, which are converted to JavaScript through JSON so
+// that Go structs are passed the same way they're marshaled, honoring json
+// tags.
+func Call(vm *goja.Runtime, fn goja.Value, args any) (goja.Value, error) {
+	call, ok := goja.AssertFunction(fn)
+	if !ok {
+		return nil, errors.New("value is not a function")
+	}
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("converting arguments to JSON: %w", err)
+	}
+	parse, _ := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+	jsArgs, err := parse(goja.Undefined(), vm.ToValue(string(argsJSON)))
 	if err != nil {
 		return nil, err
 	}
+	return call(goja.Undefined(), jsArgs)
+}
+
+func evalJS(vm *goja.Runtime, program *goja.Program) (map[string]goja.Value, error) {
+	_, err := vm.RunProgram(program)
+	if err != nil {
+		return nil, err
+	}
+	exports := map[string]goja.Value{}
 	globals := vm.Get(globalsName)
-	// Return null if the globals variable is not defined.
+	// Return no exports if the globals variable is not defined.
 	if globals == nil || goja.IsNull(globals) || goja.IsUndefined(globals) {
-		return nil, nil
+		return exports, nil
 	}
-	val := globals.ToObject(vm).Get("default")
-	// Right now we return a goja value, but this might have to change if we
-	// decide to move to V8
-	return val, nil
+	obj := globals.ToObject(vm)
+	for _, key := range obj.Keys() {
+		// Right now we return goja values, but this might have to change if we
+		// decide to move to V8
+		exports[key] = obj.Get(key)
+	}
+	return exports, nil
 }
 
 // Default tsConfig
@@ -58,18 +293,54 @@ const tsConfig = `
 const globalsName = "globals"
 
 func Build(entrypoint string, opts Options) ([]byte, error) {
-	bundle := api.Build(api.BuildOptions{
+	bundle, err := Compile(entrypoint, opts)
+	if err != nil {
+		return nil, err
+	}
+	return bundle.Code, nil
+}
+
+// Compile bundles the entrypoint and everything it imports. If opts.CacheDir is
+// set, a cached bundle is used when none of its inputs have changed.
+func Compile(entrypoint string, opts Options) (*Bundle, error) {
+	if opts.CacheDir == "" {
+		return build(entrypoint, opts)
+	}
+	if cached := loadCached(entrypoint, opts); cached != nil {
+		return cached, nil
+	}
+	bundle, err := build(entrypoint, opts)
+	if err != nil {
+		return nil, err
+	}
+	// The cache is only an optimization, so failing to write to it isn't an
+	// error.
+	_ = storeCached(bundle, opts)
+	return bundle, nil
+}
+
+// buildOptions returns the esbuild options that bundle the entrypoint.
+func buildOptions(entrypoint string, opts Options) api.BuildOptions {
+	return api.BuildOptions{
 		EntryPoints: []string{entrypoint},
 
-		Bundle:      true,
-		Charset:     api.CharsetUTF8,
-		GlobalName:  globalsName,
-		Plugins:     opts.Plugins,
-		Platform:    api.PlatformBrowser,
-		Target:      api.ES2015, // ES6 == ES2015
-		TsconfigRaw: tsConfig,
-		Write:       false,
-	})
+		Bundle:            true,
+		Charset:           api.CharsetUTF8,
+		GlobalName:        globalsName,
+		Metafile:          true,
+		NodePaths:         opts.NodePaths,
+		Plugins:           opts.Plugins,
+		Platform:          api.PlatformBrowser,
+		ResolveExtensions: opts.ResolveExtensions,
+		Sourcemap:         api.SourceMapInline,
+		Target:            api.ES2015, // ES6 == ES2015
+		TsconfigRaw:       tsConfig,
+		Write:             false,
+	}
+}
+
+func build(entrypoint string, opts Options) (*Bundle, error) {
+	bundle := api.Build(buildOptions(entrypoint, opts))
 
 	if len(bundle.Errors) > 0 {
 		msg := fmt.Sprintf("%d syntax errors when compiling %s", len(bundle.Errors), entrypoint)
@@ -80,5 +351,61 @@ func Build(entrypoint string, opts Options) ([]byte, error) {
 		return nil, fmt.Errorf("expected 1 output file, got %d", len(bundle.OutputFiles))
 	}
 
-	return bundle.OutputFiles[0].Contents, nil
+	inputs, imports, err := parseMetafile(bundle.Metafile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bundle{
+		Entrypoint: entrypoint,
+		Code:       bundle.OutputFiles[0].Contents,
+		Inputs:     inputs,
+		imports:    imports,
+	}, nil
+}
+
+// parseMetafile returns the absolute paths of the inputs listed in an esbuild
+// metafile, and how their imports were resolved. Paths in the metafile are
+// relative to the working directory.
+func parseMetafile(metafile string) ([]string, []resolvedImport, error) {
+	var meta struct {
+		Inputs map[string]struct {
+			Imports []struct {
+				Path     string `json:"path"`
+				Kind     string `json:"kind"`
+				Original string `json:"original"`
+				External bool   `json:"external"`
+			} `json:"imports"`
+		} `json:"inputs"`
+	}
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return nil, nil, fmt.Errorf("parsing esbuild metafile: %w", err)
+	}
+
+	inputs := make([]string, 0, len(meta.Inputs))
+	imports := []resolvedImport{}
+	for path, input := range meta.Inputs {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		inputs = append(inputs, abs)
+		for _, imp := range input.Imports {
+			if imp.External {
+				continue
+			}
+			resolved, err := filepath.Abs(imp.Path)
+			if err != nil {
+				return nil, nil, err
+			}
+			imports = append(imports, resolvedImport{
+				Importer: abs,
+				Path:     imp.Original,
+				Kind:     imp.Kind,
+				Resolved: resolved,
+			})
+		}
+	}
+	sort.Strings(inputs)
+	return inputs, imports, nil
 }
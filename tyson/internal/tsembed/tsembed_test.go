@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"go.jetpack.io/tyson/msgerror"
 )
 
 func TestEval(t *testing.T) {
@@ -55,3 +56,51 @@ func TestEval(t *testing.T) {
 		})
 	}
 }
+
+func TestEvalExports(t *testing.T) {
+	input := `
+		export const staging = { env: "staging" }
+		export const prod = { env: "prod" }
+		export default staging
+	`
+	path := filepath.Join(t.TempDir(), "input.ts")
+	err := os.WriteFile(path, []byte(input), 0644)
+	assert.NoError(t, err)
+
+	exports, err := EvalExports(path, Options{})
+	assert.NoError(t, err)
+	assert.Len(t, exports, 3)
+	for name, expected := range map[string]string{
+		"default": `{"env": "staging"}`,
+		"staging": `{"env": "staging"}`,
+		"prod":    `{"env": "prod"}`,
+	} {
+		jsonBytes, err := json.Marshal(exports[name])
+		assert.NoError(t, err)
+		assert.JSONEq(t, expected, string(jsonBytes))
+	}
+
+	_, err = EvalExport(path, "missing", Options{})
+	assert.ErrorContains(t, err, `export "missing" not found`)
+}
+
+func TestRuntimeErrorLocation(t *testing.T) {
+	dir := t.TempDir()
+	lib := "export function port(server: any) {\n  return server.listen.port;\n}\n"
+	input := "import { port } from \"./lib\";\n\nexport default {\n  port: port({}),\n}\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "lib.ts"), []byte(lib), 0644))
+	path := filepath.Join(dir, "input.ts")
+	assert.NoError(t, os.WriteFile(path, []byte(input), 0644))
+
+	_, err := Eval(path, Options{})
+	var msgErr *msgerror.Error
+	if !assert.ErrorAs(t, err, &msgErr) {
+		return
+	}
+	assert.Contains(t, err.Error(), "TypeError: Cannot read property 'port' of undefined")
+	messages := msgErr.Messages()
+	if assert.Len(t, messages, 1) {
+		assert.Contains(t, messages[0], "lib.ts:2:")
+		assert.Contains(t, messages[0], "return server.listen.port;")
+	}
+}
@@ -0,0 +1,180 @@
+// Package yamldoc reads YAML documents as trees of nodes, which keep the
+// order of keys and the comments that decoding into Go values loses.
+package yamldoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// jsonNumberRegex matches numbers written the same way in YAML, JSON and
+// TypeScript.
+var jsonNumberRegex = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// JSONNumber reports whether a YAML number is also written the same way in
+// JSON and TypeScript.
+func JSONNumber(s string) bool {
+	return jsonNumberRegex.MatchString(s)
+}
+
+// Parse parses a file with a single YAML document. Empty files are documents
+// without content.
+func Parse(data []byte) (*yaml.Node, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var doc yaml.Node
+	if err := decoder.Decode(&doc); errors.Is(err, io.EOF) {
+		return &yaml.Node{Kind: yaml.DocumentNode}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var extra yaml.Node
+	if err := decoder.Decode(&extra); !errors.Is(err, io.EOF) {
+		return nil, errors.New("only files with a single YAML document are supported")
+	}
+	return &doc, nil
+}
+
+// Resolve follows aliases to the node they refer to.
+func Resolve(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
+
+// Pair is a key of a mapping and its value.
+type Pair struct {
+	Key   string
+	Value *yaml.Node
+}
+
+// Flatten returns the key/value pairs of a mapping, with merge keys replaced
+// by the pairs they merge in. Later keys override earlier ones, but keep the
+// earlier position.
+func Flatten(n *yaml.Node) []Pair {
+	var pairs []Pair
+	index := map[string]int{}
+	add := func(p Pair) {
+		if i, ok := index[p.Key]; ok {
+			pairs[i] = p
+			return
+		}
+		index[p.Key] = len(pairs)
+		pairs = append(pairs, p)
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.ShortTag() != "!!merge" {
+			add(Pair{Key: key.Value, Value: value})
+			continue
+		}
+		sources := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			sources = value.Content
+		}
+		for _, source := range sources {
+			if source = Resolve(source); source.Kind == yaml.MappingNode {
+				for _, p := range Flatten(source) {
+					add(p)
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+// ToJSON converts a YAML document to JSON, keeping the order of keys. Merge
+// keys are expanded, and empty documents are null.
+func ToJSON(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	if doc.Kind == yaml.DocumentNode {
+		if len(doc.Content) == 0 {
+			return []byte("null"), nil
+		}
+		doc = doc.Content[0]
+	}
+	if err := writeJSON(&buf, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, n *yaml.Node) error {
+	n = Resolve(n)
+	switch n.Kind {
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i, p := range Flatten(n) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(Quote(p.Key) + ":")
+			if err := writeJSON(buf, p.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range n.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+
+	switch n.ShortTag() {
+	case "!!null":
+		buf.WriteString("null")
+		return nil
+	case "!!bool":
+		var b bool
+		if err := n.Decode(&b); err == nil {
+			buf.WriteString(strconv.FormatBool(b))
+			return nil
+		}
+	case "!!int", "!!float":
+		if JSONNumber(n.Value) {
+			buf.WriteString(n.Value)
+			return nil
+		}
+		// Other notations, such as 0x1F, 1_000 or .5.
+		var f float64
+		if err := n.Decode(&f); err == nil {
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				return fmt.Errorf("line %d: %s can't be represented in JSON", n.Line, n.Value)
+			}
+			buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+			return nil
+		}
+	}
+	// Strings, as well as timestamps and other values that don't have a JSON
+	// equivalent. Decoding takes care of !!binary values.
+	s := n.Value
+	_ = n.Decode(&s)
+	buf.WriteString(Quote(s))
+	return nil
+}
+
+// Quote quotes a string for JSON and TypeScript.
+func Quote(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s) // can't fail for strings
+	return string(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
@@ -0,0 +1,57 @@
+package yamldoc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "keeps the order of keys",
+			input:    "b: 1\na: 2\nc:\n  z: true\n  y: null\n",
+			expected: `{"b":1,"a":2,"c":{"z":true,"y":null}}`,
+		},
+		{
+			name:     "expands merge keys and aliases",
+			input:    "base: &base\n  a: 1\n  b: 2\nprod:\n  <<: *base\n  b: 3\n  c: *base\n",
+			expected: `{"base":{"a":1,"b":2},"prod":{"a":1,"b":3,"c":{"a":1,"b":2}}}`,
+		},
+		{
+			name:     "scalars",
+			input:    "- 0x1F\n- 1_000\n- .5\n- 1e3\n- yes\n- '1'\n- \"<&>\"\n- 2001-12-14\n- |\n  line\n",
+			expected: `[31,1000,0.5,1e3,"yes","1","<&>","2001-12-14","line\n"]`,
+		},
+		{
+			name:     "empty documents are null",
+			input:    "# nothing here\n",
+			expected: `null`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.input))
+			require.NoError(t, err)
+			result, err := ToJSON(doc)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(result))
+		})
+	}
+}
+
+func TestToJSONErrors(t *testing.T) {
+	_, err := Parse([]byte("a: 1\n---\nb: 2\n"))
+	assert.ErrorContains(t, err, "single YAML document")
+
+	doc, err := Parse([]byte("a: .inf\n"))
+	require.NoError(t, err)
+	_, err = ToJSON(doc)
+	assert.ErrorContains(t, err, "can't be represented in JSON")
+}
@@ -33,6 +33,18 @@ func (e *Error) Unwrap() error {
 	return e.err
 }
 
+// Files returns the files that the messages point to, as esbuild reports
+// them.
+func (e *Error) Files() []string {
+	files := []string{}
+	for _, msg := range e.messages {
+		if msg.Location != nil && msg.Location.File != "" {
+			files = append(files, msg.Location.File)
+		}
+	}
+	return files
+}
+
 func (e *Error) Messages() []string {
 	isTerminal := isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())
 	formatted := esbuild.FormatMessages(e.messages, esbuild.FormatMessagesOptions{
@@ -1,6 +1,8 @@
 package tyson
 
 import (
+	"context"
+
 	"go.jetpack.io/tyson/api"
 )
 
@@ -9,6 +11,72 @@ func Eval(tsonPath string) ([]byte, error) {
 	return api.Eval(tsonPath)
 }
 
+// EvalExport is like Eval, but it returns the export with the given name
+// instead of the default export.
+func EvalExport(tsonPath string, name string) ([]byte, error) {
+	return api.EvalExport(tsonPath, name)
+}
+
+// EvalWithArgs evaluates a tson file whose default export is a function, such
+// as `export default (args: { env: string }) => ({...})`, by calling it with
+// args. args can be a Go map or struct, and is passed to the function the same
+// way json.Marshal would encode it.
+func EvalWithArgs(tsonPath string, args any) ([]byte, error) {
+	return api.EvalWithArgs(tsonPath, args)
+}
+
+// EvalContext evaluates a tson file with the given options, such as which
+// export to evaluate and limits on how long evaluation may take. Evaluation is
+// interrupted if ctx is done.
+func EvalContext(ctx context.Context, tsonPath string, opts api.Options) ([]byte, error) {
+	return api.EvalContext(ctx, tsonPath, opts)
+}
+
+// EvalExports evaluates a tson file and returns all of its exports as
+// JSON-encoded byte slices, keyed by export name. The default export is stored
+// under the name "default".
+func EvalExports(tsonPath string) (map[string][]byte, error) {
+	return api.EvalExports(tsonPath)
+}
+
+// EvalExportsContext is like EvalExports, but with options.
+func EvalExportsContext(
+	ctx context.Context,
+	tsonPath string,
+	opts api.Options,
+) (map[string][]byte, error) {
+	return api.EvalExportsContext(ctx, tsonPath, opts)
+}
+
+// Compile compiles a tson file into a program that can be evaluated many
+// times, concurrently, without compiling it again. With opts.CacheDir set,
+// compiled programs are also cached on disk, and reused until one of the files
+// they were compiled from changes.
+func Compile(tsonPath string, opts api.CompileOptions) (*api.Program, error) {
+	return api.Compile(tsonPath, opts)
+}
+
+// Watch evaluates a tson file, and re-evaluates it every time the file or any
+// of its imports changes. Results, including errors, are delivered on the
+// returned channel until ctx is done.
+func Watch(ctx context.Context, tsonPath string, opts api.WatchOptions) <-chan api.Update {
+	return api.Watch(ctx, tsonPath, opts)
+}
+
+// Format re-indents the contents of a tson file: two space indentation, no
+// trailing whitespace and a single newline at the end. Code within lines is
+// left as is. It returns an error if the source has syntax errors.
+func Format(filename string, source []byte) ([]byte, error) {
+	return api.Format(filename, source)
+}
+
+// Convert converts a JSON or YAML file into TSON. Keys keep their order and
+// are only quoted when they're not valid identifiers, and YAML comments are
+// kept as // comments.
+func Convert(path string, opts api.ConvertOptions) ([]byte, error) {
+	return api.Convert(path, opts)
+}
+
 // Unmarshal is a convenience function that first evaluates the given TSON file,
 // and then unmarshals the result into the given go struct.
 // Internally it unmarshals using json.Unmarshal, so the behavior is the same.